@@ -14,47 +14,312 @@
 package main
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 	"gopkg.in/yaml.v2"
 )
 
 const (
 	metricsPath = "/metrics"
 	localIP     = "0.0.0.0"
+
+	// maxStatementLabelLength is the maximum length of the query classifier statement labels
+	maxStatementLabelLength = 200
+
+	// collectorLabel is the label of the exporter metrics about its collectors
+	collectorLabel = "collector"
 )
 
+// errNotFound is returned for resources the MaxScale version does not provide
+var errNotFound = errors.New("the MaxScale resource does not exist")
+
+// serviceStates lists the states a MaxScale service can be in
+var serviceStates = []string{"Started", "Stopped", "Failed", "Allocated"}
+
+// threadStates lists the states a MaxScale worker thread can be in
+var threadStates = []string{"Active", "Draining", "Dormant"}
+
+// listenerStates lists the states a MaxScale listener can be in
+var listenerStates = []string{"Running", "Stopped", "Failed", "Created"}
+
+// monitorStates lists the states a MaxScale monitor can be in
+var monitorStates = []string{"Running", "Stopped"}
+
 var (
-	maxScaleUrl                   string // URL of maxscale instance
-	maxScaleUsername              string // Username for maxscale REST API authentication
-	maxScalePassword              string // Password for maxscale REST API authentication
-	maxScaleExporterPort          string // Port for this exporter to run on
-	maxScaleCACertificate         string // File containing CA certificate
-	maxctrlExporterConfigFile     string // File containing exporter config
-	maxScaleTLSInsecureSkipVerify bool   // Disable TLS verify
+	maxScaleUrl                   string            // URL of maxscale instance
+	maxScaleUsername              string            // Username for maxscale REST API authentication
+	maxScalePassword              string            // Password for maxscale REST API authentication
+	maxScaleExporterPort          string            // Port for this exporter to run on
+	maxScaleCACertificate         string            // File containing CA certificate
+	maxctrlExporterConfigFile     string            // File containing exporter config
+	maxScaleTLSInsecureSkipVerify bool              // Disable TLS verify
+	constLabels                   map[string]string // Labels added to every metric
+	nodeNameLabel                 string            // Label for the MaxScale node name added to every metric
+	metricNamespace               string            // Prefix of the metric names
+	relabelConfigs                []RelabelConfig   // Relabeling rules applied to every series
+	exporterOptions               ExporterOptions
 )
 
 type ConfigValues struct {
-	Url                   string `yaml:"url"`
-	Username              string `yaml:"username"`
-	Password              string `yaml:"password"`
-	ExporterPort          string `yaml:"exporter_port"`
-	CACertificate         string `yaml:"caCertificate"`
-	TLSInsecureSkipVerify bool   `yaml:"tlsInsecureSkipVerify" default:"false"`
+	Url                   string            `yaml:"url"`
+	Username              string            `yaml:"username"`
+	Password              string            `yaml:"password"`
+	ExporterPort          string            `yaml:"exporter_port"`
+	CACertificate         string            `yaml:"caCertificate"`
+	TLSInsecureSkipVerify bool              `yaml:"tlsInsecureSkipVerify" default:"false"`
+	Labels                map[string]string `yaml:"labels"`
+	NodeNameLabel         string            `yaml:"node_name_label"`
+	Namespace             string            `yaml:"namespace"`
+	RelabelConfigs        []RelabelConfig   `yaml:"metric_relabel_configs"`
+	Options               ExporterOptions   `yaml:",inline"`
+}
+
+// ExporterOptions contains the settings of the collectors
+type ExporterOptions struct {
+	// Number of cached statements with the most hits to export, 0 disables the statement listing
+	QueryClassifierTopStatements int                 `yaml:"query_classifier_top_statements"`
+	Sessions                     SessionsOptions     `yaml:"sessions"`
+	ServerLabels                 ServerLabelsOptions `yaml:"server_labels"`
+	Parameters                   ParametersOptions   `yaml:"parameters"`
+	CustomMetrics                []CustomMetric      `yaml:"custom_metrics"`
+	// Maximum number of series per collector, the default key applies to collectors without
+	// an own limit, 0 disables the limit
+	SeriesLimits map[string]int `yaml:"series_limits"`
+	// Minimum time between two refreshes of a collector, in between the cached series are
+	// exported. The default key applies to collectors without an own interval.
+	RefreshIntervals map[string]Duration `yaml:"refresh_intervals"`
+}
+
+// refreshInterval returns the minimum time between two refreshes of a collector, 0 to
+// refresh on every scrape
+func (o ExporterOptions) refreshInterval(collector string) time.Duration {
+	interval, ok := o.RefreshIntervals[collector]
+	if !ok {
+		interval = o.RefreshIntervals["default"]
+	}
+	return time.Duration(float64(interval) * float64(time.Second))
+}
+
+// seriesLimit returns the maximum number of series of a collector, 0 if unlimited
+func (o ExporterOptions) seriesLimit(collector string) int {
+	if limit, ok := o.SeriesLimits[collector]; ok {
+		return limit
+	}
+	return o.SeriesLimits["default"]
+}
+
+// CustomMetric defines a metric read from the MaxScale REST API by the config file
+type CustomMetric struct {
+	// Metric name without the namespace
+	Name string `yaml:"name"`
+	Help string `yaml:"help"`
+	// gauge or counter, default is gauge
+	Type string `yaml:"type"`
+	// REST API path without the /v1 prefix, e.g. /servers
+	Path string `yaml:"path"`
+	// Selector of the value in each object of data, e.g. attributes.statistics.connections
+	Value string `yaml:"value"`
+	// Label names with the selectors of their values
+	Labels map[string]string `yaml:"labels"`
+	// Numbers for the values that are not numbers themselves, e.g. states
+	ValueMapping map[string]float64 `yaml:"value_mapping"`
+}
+
+// metricNameRegexp matches valid Prometheus metric names
+var metricNameRegexp = regexp.MustCompile("^[a-zA-Z_:][a-zA-Z0-9_:]*$")
+
+// labelNames returns the sorted label names of the custom metric
+func (c CustomMetric) labelNames() []string {
+	names := make([]string, 0, len(c.Labels))
+	for name := range c.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// labelValues returns the label values of the custom metric for an object in the order of labelNames
+func (c CustomMetric) labelValues(object interface{}) []string {
+	names := c.labelNames()
+	values := make([]string, len(names))
+	for i, name := range names {
+		value, _ := selectJSON(object, c.Labels[name])
+		values[i] = jsonString(value)
+	}
+	return values
+}
+
+// value returns the value of the custom metric for an object, mapped if a mapping exists
+func (c CustomMetric) value(object interface{}) (float64, bool) {
+	value, ok := selectJSON(object, c.Value)
+	if !ok {
+		return 0, false
+	}
+	if mapped, ok := c.ValueMapping[jsonString(value)]; ok {
+		return mapped, true
+	}
+	return numericValue(value)
+}
+
+// ParametersOptions contains the settings of the parameters collector
+type ParametersOptions struct {
+	Enabled bool `yaml:"enabled"`
+	// Regular expressions the parameter names must match and must not match, empty ones are ignored
+	Allow string `yaml:"allow"`
+	Deny  string `yaml:"deny"`
+
+	allow *regexp.Regexp
+	deny  *regexp.Regexp
+}
+
+// exported reports whether the parameter passes the allow and deny regular expressions
+func (o ParametersOptions) exported(parameter string) bool {
+	return (o.allow == nil || o.allow.MatchString(parameter)) && (o.deny == nil || !o.deny.MatchString(parameter))
+}
+
+// parameterObjectTypes maps the object types of the parameters collector to their REST API paths
+var parameterObjectTypes = []struct {
+	objectType string
+	path       string
+}{
+	{"server", "/servers"},
+	{"service", "/services"},
+	{"monitor", "/monitors"},
+	{"filter", "/filters"},
+	{"listener", "/listeners"},
+}
+
+// ServerLabelsOptions contains the extra labels of the server metrics
+type ServerLabelsOptions struct {
+	// Server parameters added as labels, any of port, rank, priority and monitor
+	Parameters []string `yaml:"parameters"`
+	// Labels assigned to the servers matching a rule, later rules override earlier ones
+	Rules []ServerLabelRule `yaml:"rules"`
+}
+
+// ServerLabelRule assigns labels to servers by name or by address. A rule without
+// servers and address matches all servers.
+type ServerLabelRule struct {
+	Servers []string          `yaml:"servers"`
+	Address string            `yaml:"address"`
+	Labels  map[string]string `yaml:"labels"`
+
+	address *regexp.Regexp
+}
+
+// serverParameterLabels lists the server parameters that can be added as labels
+var serverParameterLabels = []string{"port", "rank", "priority", "monitor"}
+
+// labelRegexp matches valid Prometheus label names
+var labelRegexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+func (r ServerLabelRule) matches(name string, address string) bool {
+	if len(r.Servers) == 0 && r.address == nil {
+		return true
+	}
+	return contains(r.Servers, name) || (r.address != nil && r.address.MatchString(address))
+}
+
+// labelNames returns the names of the extra server labels
+func (o ServerLabelsOptions) labelNames() []string {
+	names := append([]string{}, o.Parameters...)
+	var ruleNames []string
+	for _, rule := range o.Rules {
+		for name := range rule.Labels {
+			if !contains(names, name) && !contains(ruleNames, name) {
+				ruleNames = append(ruleNames, name)
+			}
+		}
+	}
+	sort.Strings(ruleNames)
+	return append(names, ruleNames...)
+}
+
+// labelValues returns the values of the extra server labels in the order of labelNames
+func (o ServerLabelsOptions) labelValues(name string, address string, parameters map[string]string) []string {
+	values := make(map[string]string)
+	for _, parameter := range o.Parameters {
+		values[parameter] = parameters[parameter]
+	}
+	for _, rule := range o.Rules {
+		if rule.matches(name, address) {
+			for label, value := range rule.Labels {
+				values[label] = value
+			}
+		}
+	}
+
+	names := o.labelNames()
+	labelValues := make([]string, len(names))
+	for i, label := range names {
+		labelValues[i] = values[label]
+	}
+	return labelValues
+}
+
+// SessionsOptions contains the settings of the sessions collector
+type SessionsOptions struct {
+	Enabled bool `yaml:"enabled"`
+	// Session attributes the session counts are aggregated by, any of service, user and remote
+	Labels []string `yaml:"labels"`
+	// Upper bounds of the session age and idle time histogram buckets in seconds
+	AgeBuckets  []float64 `yaml:"age_buckets"`
+	IdleBuckets []float64 `yaml:"idle_buckets"`
+	// Limits sessions are checked against, a zero value disables the check
+	Thresholds SessionThresholds `yaml:"thresholds"`
+	// Log the sessions exceeding a threshold together with their last statements
+	LogOffendingSessions bool `yaml:"log_offending_sessions"`
+}
+
+// SessionThresholds contains the limits of the stuck session detection
+type SessionThresholds struct {
+	// Seconds a session may stay idle inside an open transaction
+	IdleInTransaction float64 `yaml:"idle_in_transaction_seconds"`
+	// Seconds a session may exist
+	Age float64 `yaml:"age_seconds"`
+	// Number of queries a session may wait for
+	PendingQueries int `yaml:"pending_queries"`
+}
+
+// rules returns the names of the enabled thresholds
+func (t SessionThresholds) rules() []string {
+	var rules []string
+	if t.IdleInTransaction > 0 {
+		rules = append(rules, "idle_in_transaction")
+	}
+	if t.Age > 0 {
+		rules = append(rules, "age")
+	}
+	if t.PendingQueries > 0 {
+		rules = append(rules, "pending_queries")
+	}
+	return rules
 }
 
+// sessionLabels lists the session attributes sessions can be aggregated by
+var sessionLabels = []string{"service", "user", "remote"}
+
 // MaxScale contains connection parameters to the server and metric maps
 type MaxScale struct {
 	url                   string
@@ -63,15 +328,58 @@ type MaxScale struct {
 	transport             *http.Transport
 	up                    prometheus.Gauge
 	totalScrapes          prometheus.Counter
+	seriesDropped         *prometheus.CounterVec
+	cacheAge              *prometheus.GaugeVec
 	serverMetrics         map[string]Metric
 	serviceMetrics        map[string]Metric
 	monitorMetrics        map[string]Metric
 	maxscaleStatusMetrics map[string]Metric
 	statusMetrics         map[string]Metric
+	routerMetrics         map[string]Metric
+	moduleMetrics         map[string]Metric
+	memoryMetrics         map[string]Metric
+	qcMetrics             map[string]Metric
+	sessionMetrics        map[string]Metric
+	filterMetrics         map[string]Metric
+	listenerMetrics       map[string]Metric
+	parameterMetrics      map[string]Metric
+	customMetrics         map[string]Metric
+	options               ExporterOptions
+
+	// mutex guards the state kept between scrapes
+	mutex sync.Mutex
+	// lastEvents holds the last seen triggered_at value per server
+	lastEvents map[string]string
+	// eventCounts holds the number of monitor events seen per event type
+	eventCounts map[string]int
+	// lastUptime holds the MaxScale uptime of the previous scrape, -1 before the first scrape
+	lastUptime int
+	// restarts holds the number of MaxScale restarts detected
+	restarts int
+	// nodeName holds the node name MaxScale reported in the last scrape
+	nodeName string
+	// caches holds the series of the collectors with a refresh interval
+	caches map[string]collectorCache
+	// responses holds the REST API responses of the current scrape by path, so collectors
+	// reading the same path share one request
+	responses map[string]response
+}
+
+// response is a REST API response read during a scrape
+type response struct {
+	body []byte
+	err  error
+}
+
+// collectorCache holds the series of a collector exported between its refreshes
+type collectorCache struct {
+	series      []prometheus.Metric
+	refreshedAt time.Time
 }
 
 // NewExporter creates a new instance of the MaxScale
-func NewExporter(url string, username string, password string, caCertificate string, tlsInsecureSkipVerify bool) (*MaxScale, error) {
+func NewExporter(url string, username string, password string, caCertificate string, tlsInsecureSkipVerify bool,
+	options ExporterOptions) (*MaxScale, error) {
 	rootCAs, _ := x509.SystemCertPool()
 	if rootCAs == nil {
 		rootCAs = x509.NewCertPool()
@@ -95,7 +403,23 @@ func NewExporter(url string, username string, password string, caCertificate str
 		InsecureSkipVerify: tlsInsecureSkipVerify,
 	}}
 
-	return &MaxScale{
+	seriesDropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "exporter_series_dropped_total",
+		Help:      "Series dropped for exceeding the series limit of the collector",
+	}, []string{collectorLabel})
+	cacheAge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "exporter_collector_cache_age_seconds",
+		Help:      "Age of the series exported by the collector",
+	}, []string{collectorLabel})
+	for _, collector := range collectors {
+		if options.seriesLimit(collector.name) > 0 {
+			seriesDropped.WithLabelValues(collector.name)
+		}
+	}
+
+	exporter := &MaxScale{
 		url:       url,
 		username:  username,
 		password:  password,
@@ -110,12 +434,33 @@ func NewExporter(url string, username string, password string, caCertificate str
 			Name:      "exporter_total_scrapes",
 			Help:      "Current total MaxScale scrapes",
 		}),
-		serverMetrics:         ServerMetrics,
+		seriesDropped:         seriesDropped,
+		cacheAge:              cacheAge,
+		serverMetrics:         newServerMetrics(options.ServerLabels.labelNames()),
 		serviceMetrics:        ServiceMetrics,
 		maxscaleStatusMetrics: MaxscaleStatusMetrics,
 		statusMetrics:         StatusMetrics,
 		monitorMetrics:        MonitorMetrics,
-	}, nil
+		routerMetrics:         RouterMetrics,
+		moduleMetrics:         ModuleMetrics,
+		memoryMetrics:         MemoryMetrics,
+		qcMetrics:             QueryClassifierMetrics,
+		sessionMetrics:        newSessionMetrics(options.Sessions.Labels),
+		filterMetrics:         FilterMetrics,
+		listenerMetrics:       ListenerMetrics,
+		parameterMetrics:      ParameterMetrics,
+		customMetrics:         newCustomMetrics(options.CustomMetrics),
+		options:               options,
+		lastEvents:            make(map[string]string),
+		eventCounts:           make(map[string]int),
+		lastUptime:            -1,
+		caches:                make(map[string]collectorCache),
+	}
+
+	if err := exporter.checkCustomMetricNames(); err != nil {
+		return nil, err
+	}
+	return exporter, nil
 }
 
 // Describe describes all the metrics ever exported by the MaxScale exporter. It
@@ -137,74 +482,323 @@ func (m *MaxScale) Describe(ch chan<- *prometheus.Desc) {
 		ch <- m.Desc
 	}
 
+	for _, m := range m.monitorMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.routerMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.moduleMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.memoryMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.qcMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.sessionMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.filterMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.listenerMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.parameterMetrics {
+		ch <- m.Desc
+	}
+
+	for _, m := range m.customMetrics {
+		ch <- m.Desc
+	}
+
 	ch <- m.up.Desc()
 	ch <- m.totalScrapes.Desc()
+	m.seriesDropped.Describe(ch)
+	m.cacheAge.Describe(ch)
+}
+
+// builtinMetrics returns the metric maps of all collectors but the custom metrics
+func (m *MaxScale) builtinMetrics() []metrics {
+	return []metrics{m.serverMetrics, m.serviceMetrics, m.maxscaleStatusMetrics, m.statusMetrics,
+		m.monitorMetrics, m.routerMetrics, m.moduleMetrics, m.memoryMetrics, m.qcMetrics,
+		m.sessionMetrics, m.filterMetrics, m.listenerMetrics, m.parameterMetrics}
+}
+
+// allMetrics returns the metric maps of all collectors
+func (m *MaxScale) allMetrics() []metrics {
+	return append(m.builtinMetrics(), m.customMetrics)
+}
+
+// checkCustomMetricNames returns an error if a custom metric has the name of a built-in
+// metric, the registration of the exporter panics on such metrics
+func (m *MaxScale) checkCustomMetricNames() error {
+	for key, custom := range m.customMetrics {
+		if key == "up" || strings.HasPrefix(key, "exporter_") {
+			return fmt.Errorf("custom metric '%s' uses a name reserved for the exporter", key)
+		}
+		for _, metrics := range m.builtinMetrics() {
+			for _, metric := range metrics {
+				if metric.name == custom.name {
+					return fmt.Errorf("custom metric '%s' has the name of the built-in metric %s", key, metric.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkLabelNames returns an error if any of the labels is already used by a metric, the
+// registration of the exporter panics on such labels
+func (m *MaxScale) checkLabelNames(labels []string) error {
+	for _, label := range labels {
+		if label == collectorLabel {
+			return fmt.Errorf("label '%s' is used by the exporter metrics", label)
+		}
+		for _, metrics := range m.allMetrics() {
+			for _, metric := range metrics {
+				if contains(metric.labels, label) {
+					return fmt.Errorf("label '%s' is used by %s", label, metric.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// collectors lists the parts of the MaxScale metrics with their parse functions, in
+// the order they are scraped
+var collectors = []struct {
+	name  string
+	parse func(m *MaxScale, ch chan<- prometheus.Metric) error
+}{
+	{"servers", (*MaxScale).parseServers},
+	{"services", (*MaxScale).parseServices},
+	{"maxscale", (*MaxScale).parseMaxscaleStatus},
+	{"threads", (*MaxScale).parseThreadStatus},
+	{"monitors", (*MaxScale).parseMonitors},
+	{"modules", (*MaxScale).parseModules},
+	{"memory", (*MaxScale).parseMemory},
+	{"query_classifier", (*MaxScale).parseQueryClassifier},
+	{"sessions", (*MaxScale).parseSessions},
+	{"filters", (*MaxScale).parseFilters},
+	{"listeners", (*MaxScale).parseListeners},
+	{"parameters", (*MaxScale).parseParameters},
+	{"custom_metrics", (*MaxScale).parseCustomMetrics},
 }
 
 // Collect fetches the stats from configured MaxScale location and delivers them
 // as Prometheus metrics. It implements prometheus.Collector.
 func (m *MaxScale) Collect(ch chan<- prometheus.Metric) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	m.totalScrapes.Inc()
 
 	var parseErrors = false
 
-	if err := m.parseServers(ch); err != nil {
-		parseErrors = true
-		log.Print(err)
+	m.responses = make(map[string]response)
+	for _, collector := range collectors {
+		if err := m.collect(collector.name, collector.parse, ch); err != nil {
+			parseErrors = true
+			log.Print(err)
+		}
+	}
+	m.responses = nil
+
+	if parseErrors {
+		m.up.Set(0)
+	} else {
+		m.up.Set(1)
 	}
 
-	if err := m.parseServices(ch); err != nil {
-		parseErrors = true
-		log.Print(err)
+	ch <- m.up
+	ch <- m.totalScrapes
+	m.seriesDropped.Collect(ch)
+	m.cacheAge.Collect(ch)
+}
+
+// knownCollector reports whether the name is a collector or the default key of the
+// per-collector options
+func knownCollector(name string) bool {
+	for _, collector := range collectors {
+		if collector.name == name {
+			return true
+		}
 	}
+	return name == "default"
+}
 
-	if err := m.parseMaxscaleStatus(ch); err != nil {
-		parseErrors = true
-		log.Print(err)
+// collect exports the metrics of a collector. Beyond the series limit of the collector
+// the series are truncated by limitSeries.
+// Collectors with a refresh interval export their cached series until the interval
+// has passed since their last successful refresh. A failed refresh exports the cached
+// series rather than a partial result and is retried on the next scrape.
+func (m *MaxScale) collect(name string, parse func(m *MaxScale, ch chan<- prometheus.Metric) error,
+	ch chan<- prometheus.Metric) error {
+	interval := m.options.refreshInterval(name)
+	cache, cached := m.caches[name]
+	if cached && interval > 0 && time.Since(cache.refreshedAt) < interval {
+		m.cacheAge.WithLabelValues(name).Set(time.Since(cache.refreshedAt).Seconds())
+		for _, metric := range cache.series {
+			ch <- metric
+		}
+		return nil
 	}
 
-	if err := m.parseThreadStatus(ch); err != nil {
-		parseErrors = true
-		log.Print(err)
+	limit := m.options.seriesLimit(name)
+	if limit <= 0 && interval <= 0 {
+		return parse(m, ch)
 	}
 
-	if err := m.parseMonitors(ch); err != nil {
-		parseErrors = true
-		log.Print(err)
+	buffer := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var series []prometheus.Metric
+	go func() {
+		for metric := range buffer {
+			series = append(series, metric)
+		}
+		close(done)
+	}()
+	err := parse(m, buffer)
+	close(buffer)
+	<-done
+
+	if interval > 0 && err != nil && cached {
+		series = cache.series
+	} else if limit > 0 && len(series) > limit {
+		log.Printf("Warning: collector %s exceeded its limit of %d series, dropping %d series", name, limit, len(series)-limit)
+		m.seriesDropped.WithLabelValues(name).Add(float64(len(series) - limit))
+		series = limitSeries(series, limit)
 	}
 
-	if parseErrors {
-		m.up.Set(0)
-	} else {
-		m.up.Set(1)
+	if interval > 0 && err == nil {
+		cache = collectorCache{series: series, refreshedAt: time.Now()}
+		m.caches[name] = cache
+		cached = true
+	}
+	if interval > 0 && cached {
+		m.cacheAge.WithLabelValues(name).Set(time.Since(cache.refreshedAt).Seconds())
 	}
 
-	ch <- m.up
-	ch <- m.totalScrapes
+	for _, metric := range series {
+		ch <- metric
+	}
+	return err
+}
+
+// limitSeries returns at most limit series, shared evenly between the metrics so that no
+// metric is dropped entirely while the limit allows. The series of each metric are taken
+// in the order of their label values, compared by label name order and numeric values
+// as numbers.
+func limitSeries(series []prometheus.Metric, limit int) []prometheus.Metric {
+	families := make(map[string][]prometheus.Metric)
+	labelValues := make(map[prometheus.Metric][]string, len(series))
+	for _, metric := range series {
+		name := metric.Desc().String()
+		families[name] = append(families[name], metric)
+
+		var pb dto.Metric
+		_ = metric.Write(&pb)
+		for _, label := range pb.Label {
+			labelValues[metric] = append(labelValues[metric], label.GetValue())
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name, family := range families {
+		names = append(names, name)
+		sort.Slice(family, func(i, j int) bool { return lessLabelValues(labelValues[family[i]], labelValues[family[j]]) })
+	}
+	sort.Strings(names)
+
+	limited := make([]prometheus.Metric, 0, limit)
+	for i := 0; len(limited) < limit; i++ {
+		for _, name := range names {
+			if i < len(families[name]) && len(limited) < limit {
+				limited = append(limited, families[name][i])
+			}
+		}
+	}
+	return limited
+}
+
+// lessLabelValues compares label values one by one, numbers by their value and otherwise
+// as strings
+func lessLabelValues(a []string, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		x, errX := strconv.ParseFloat(a[i], 64)
+		y, errY := strconv.ParseFloat(b[i], 64)
+		if errX == nil && errY == nil && x != y {
+			return x < y
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
 }
 
 func (m *MaxScale) getStatistics(path string, v interface{}) error {
+	resp, ok := m.responses[path]
+	if !ok {
+		resp.body, resp.err = m.get(path)
+		if m.responses != nil {
+			m.responses[path] = resp
+		}
+	}
+	if resp.err != nil {
+		return resp.err
+	}
+
+	return json.Unmarshal(resp.body, v)
+}
+
+// get requests a path of the REST API and returns the response body
+func (m *MaxScale) get(path string) ([]byte, error) {
 	var err error
 	req, err := http.NewRequest("GET", m.url+"/v1"+path, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.SetBasicAuth(m.username, m.password)
 
 	client := &http.Client{Transport: m.transport}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error while getting %v: %v", path, err)
+		return nil, fmt.Errorf("error while getting %v: %v", path, err)
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %v", errNotFound, path)
+	}
+
 	if resp.StatusCode != 200 {
 		err = fmt.Errorf("the MaxScale statistic request failed with a status: %s", resp.Status)
-		return err
+		return nil, err
 	}
 
-	return json.NewDecoder(resp.Body).Decode(v)
+	return io.ReadAll(resp.Body)
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
 }
 
 func serverUp(status string) int {
@@ -220,11 +814,82 @@ func serverUp(status string) int {
 func (m *MaxScale) createMetricForPrometheus(metricsMap map[string]Metric, metricKey string,
 	value int, ch chan<- prometheus.Metric, labelValues ...string) {
 
+	m.createFloatMetricForPrometheus(metricsMap, metricKey, float64(value), ch, labelValues...)
+}
+
+func (m *MaxScale) createFloatMetricForPrometheus(metricsMap map[string]Metric, metricKey string,
+	value float64, ch chan<- prometheus.Metric, labelValues ...string) {
+
 	metric := metricsMap[metricKey]
 	ch <- prometheus.MustNewConstMetric(
 		metric.Desc,
 		metric.ValueType,
-		float64(value),
+		value,
+		labelValues...,
+	)
+}
+
+// createStateMetricsForPrometheus exports one series per known state with the value 1
+// for the current state and 0 for all others. The state is the last label.
+func (m *MaxScale) createStateMetricsForPrometheus(metricsMap map[string]Metric, metricKey string,
+	states []string, current string, ch chan<- prometheus.Metric, labelValues ...string) {
+
+	known := false
+	for _, state := range states {
+		value := 0
+		if state == current {
+			value = 1
+			known = true
+		}
+		m.createMetricForPrometheus(metricsMap, metricKey, value, ch, append(labelValues, state)...)
+	}
+
+	if !known && current != "" {
+		m.createMetricForPrometheus(metricsMap, metricKey, 1, ch, append(labelValues, current)...)
+	}
+}
+
+// gtidSequences returns the sequence numbers of a GTID position per replication domain.
+// A GTID position is a comma separated list of domain-server_id-sequence triplets.
+func gtidSequences(position string) map[string]float64 {
+	sequences := make(map[string]float64)
+	for _, gtid := range strings.Split(position, ",") {
+		parts := strings.Split(strings.TrimSpace(gtid), "-")
+		if len(parts) != 3 {
+			continue
+		}
+		sequence, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			continue
+		}
+		sequences[parts[0]] = sequence
+	}
+	return sequences
+}
+
+func (m *MaxScale) createHistogramForPrometheus(metricsMap map[string]Metric, metricKey string,
+	buckets []float64, values []float64, ch chan<- prometheus.Metric, labelValues ...string) {
+
+	counts := make(map[float64]uint64, len(buckets))
+	for _, bucket := range buckets {
+		counts[bucket] = 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+		for _, bucket := range buckets {
+			if value <= bucket {
+				counts[bucket]++
+			}
+		}
+	}
+
+	metric := metricsMap[metricKey]
+	ch <- prometheus.MustNewConstHistogram(
+		metric.Desc,
+		uint64(len(values)),
+		sum,
+		counts,
 		labelValues...,
 	)
 }
@@ -240,19 +905,66 @@ func (m *MaxScale) parseServers(ch chan<- prometheus.Metric) error {
 	for _, server := range servers.Data {
 		serverID := server.ID
 		serverAddress := server.Attributes.Parameters.Address
+
+		monitor := ""
+		if len(server.Relationships.Monitors.Data) > 0 {
+			monitor = server.Relationships.Monitors.Data[0].ID
+		}
+		extraLabelValues := m.options.ServerLabels.labelValues(serverID, serverAddress, map[string]string{
+			"port":     strconv.Itoa(server.Attributes.Parameters.Port),
+			"rank":     server.Attributes.Parameters.Rank,
+			"priority": strconv.Itoa(server.Attributes.Parameters.Priority),
+			"monitor":  monitor,
+		})
+
 		m.createMetricForPrometheus(m.serverMetrics, "server_connections",
-			server.Attributes.Statistics.Connections, ch, serverID, serverAddress)
+			server.Attributes.Statistics.Connections, ch, append([]string{serverID, serverAddress}, extraLabelValues...)...)
 
 		// We surround the separated list with the separator as well. This way regular expressions
 		// in labeling don't have to consider satus positions.
 		normalizedStatus := "," + strings.Replace(server.Attributes.State, ", ", ",", -1) + ","
 		m.createMetricForPrometheus(m.serverMetrics, "server_up",
-			serverUp(normalizedStatus), ch, serverID, serverAddress, normalizedStatus)
+			serverUp(normalizedStatus), ch, append([]string{serverID, serverAddress, normalizedStatus}, extraLabelValues...)...)
+
+		m.trackServerEvent(serverID, server.Attributes.LastEvent, server.Attributes.TriggeredAt, extraLabelValues, ch)
+	}
+
+	for event, count := range m.eventCounts {
+		m.createMetricForPrometheus(m.serverMetrics, "server_events_total", count, ch, event)
 	}
 
 	return nil
 }
 
+// trackServerEvent exports the timestamp of the last monitor event of a server and
+// counts the event if it has not been seen before. Events that already happened
+// when the exporter saw the server for the first time are not counted.
+func (m *MaxScale) trackServerEvent(serverID string, event string, triggeredAt string, extraLabelValues []string,
+	ch chan<- prometheus.Metric) {
+	if event == "" || triggeredAt == "" {
+		return
+	}
+
+	timestamp, err := http.ParseTime(triggeredAt)
+	if err != nil {
+		log.Printf("Could not parse the event time %q of server %s: %v", triggeredAt, serverID, err)
+		return
+	}
+
+	m.createMetricForPrometheus(m.serverMetrics, "server_last_event_timestamp",
+		int(timestamp.Unix()), ch, append([]string{serverID, event}, extraLabelValues...)...)
+
+	key := event + "@" + triggeredAt
+	previous, seen := m.lastEvents[serverID]
+	m.lastEvents[serverID] = key
+	if _, ok := m.eventCounts[event]; !ok {
+		m.eventCounts[event] = 0
+	}
+	if seen && previous != key {
+		m.eventCounts[event]++
+	}
+}
+
 func (m *MaxScale) parseServices(ch chan<- prometheus.Metric) error {
 	var services Services
 	err := m.getStatistics("/services", &services)
@@ -262,82 +974,873 @@ func (m *MaxScale) parseServices(ch chan<- prometheus.Metric) error {
 	}
 
 	for _, service := range services.Data {
+		serviceID := service.ID
+		router := service.Attributes.Router
+		statistics := service.Attributes.Statistics
+
+		// Older MaxScale versions report the connections at the attribute level only
+		connections := statistics.Connections
+		if connections == 0 {
+			connections = service.Attributes.Connections
+		}
 		m.createMetricForPrometheus(m.serviceMetrics, "service_current_sessions",
-			service.Attributes.Connections, ch, service.ID, service.Attributes.Router)
+			connections, ch, serviceID, router)
 
+		totalConnections := statistics.TotalConnections
+		if totalConnections == 0 {
+			totalConnections = service.Attributes.TotalConnections
+		}
 		m.createMetricForPrometheus(m.serviceMetrics, "service_sessions_total",
-			service.Attributes.Connections, ch, service.ID, service.Attributes.Router)
+			totalConnections, ch, serviceID, router)
 
 		m.createMetricForPrometheus(m.serviceMetrics, "service_max_connections",
-			service.Attributes.Parameters.MaxConnections, ch, service.ID, service.Attributes.Router)
-	}
+			service.Attributes.Parameters.MaxConnections, ch, serviceID, router)
 
-	return nil
-}
+		m.createMetricForPrometheus(m.serviceMetrics, "service_max_sessions",
+			statistics.MaxConnections, ch, serviceID, router)
 
-func (m *MaxScale) parseMaxscaleStatus(ch chan<- prometheus.Metric) error {
-	var maxscaleStatus MaxscaleStatus
-	err := m.getStatistics("/maxscale", &maxscaleStatus)
+		m.createMetricForPrometheus(m.serviceMetrics, "service_routed_packets",
+			statistics.RoutedPackets, ch, serviceID, router)
 
-	if err != nil {
-		return err
-	}
+		m.createMetricForPrometheus(m.serviceMetrics, "service_active_operations",
+			statistics.ActiveOperations, ch, serviceID, router)
 
-	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_uptime",
-		maxscaleStatus.Data.Attributes.Uptime, ch)
+		m.createMetricForPrometheus(m.serviceMetrics, "service_failed_auths",
+			statistics.FailedAuths, ch, serviceID, router)
 
-	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_threads",
-		maxscaleStatus.Data.Attributes.Parameters.Threads, ch)
+		m.createStateMetricsForPrometheus(m.serviceMetrics, "service_state",
+			serviceStates, service.Attributes.State, ch, serviceID, router)
 
-	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_writeq_high_water",
-		maxscaleStatus.Data.Attributes.Parameters.WriteqHighWater, ch)
+		if started, err := http.ParseTime(service.Attributes.Started); err == nil {
+			m.createMetricForPrometheus(m.serviceMetrics, "service_started",
+				int(started.Unix()), ch, serviceID, router)
+		}
 
-	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_writeq_low_water",
-		maxscaleStatus.Data.Attributes.Parameters.WriteqLowWater, ch)
+		for _, server := range service.Relationships.Servers.Data {
+			m.createMetricForPrometheus(m.serviceMetrics, "service_server", 1, ch, serviceID, server.ID)
+		}
 
-	passiveMode := 0
-	if maxscaleStatus.Data.Attributes.Parameters.Passive {
-		passiveMode = 1
-	}
+		// The filters are listed in the order of the filter chain
+		for position, filter := range service.Relationships.Filters.Data {
+			m.createMetricForPrometheus(m.serviceMetrics, "service_filter", 1, ch,
+				serviceID, filter.ID, strconv.Itoa(position+1))
+		}
 
-	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_passive", passiveMode, ch)
+		// Routers without a parser are skipped
+		parseDiagnostics, ok := routerDiagnosticsParsers[router]
+		if ok && hasDiagnostics(service.Attributes.RouterDiagnostics) {
+			if err := parseDiagnostics(m, serviceID, service.Attributes.RouterDiagnostics, ch); err != nil {
+				log.Printf("Could not parse router diagnostics of service %s: %v", serviceID, err)
+			}
+		}
+	}
 
 	return nil
 }
 
-func (m *MaxScale) parseMonitors(ch chan<- prometheus.Metric) error {
-	var monitors Monitors
-	err := m.getStatistics("/monitors", &monitors)
+// hasDiagnostics tells whether a module reported any diagnostics
+func hasDiagnostics(data json.RawMessage) bool {
+	return len(data) > 0 && string(data) != "null"
+}
 
-	if err != nil {
+// routerDiagnosticsParsers maps router modules to the parsers of their router diagnostics
+var routerDiagnosticsParsers = map[string]func(*MaxScale, string, json.RawMessage, chan<- prometheus.Metric) error{
+	"readwritesplit": (*MaxScale).parseRWSplitDiagnostics,
+	"readconnroute":  (*MaxScale).parseReadConnRouteDiagnostics,
+	"schemarouter":   (*MaxScale).parseSchemaRouterDiagnostics,
+	"binlogrouter":   (*MaxScale).parseBinlogRouterDiagnostics,
+	"kafkacdc":       (*MaxScale).parseKafkaCDCDiagnostics,
+}
+
+func (m *MaxScale) parseRWSplitDiagnostics(serviceID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics RWSplitDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
 		return err
 	}
 
-	for _, monitor := range monitors.Data {
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_queries", diagnostics.Queries, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_route_master", diagnostics.RouteMaster, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_route_slave", diagnostics.RouteSlave, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_route_all", diagnostics.RouteAll, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_rw_transactions", diagnostics.RWTransactions, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_ro_transactions", diagnostics.ROTransactions, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "rwsplit_replayed_transactions", diagnostics.ReplayedTransactions, ch, serviceID)
+
+	for _, server := range diagnostics.ServerQueryStatistics {
+		m.createMetricForPrometheus(m.routerMetrics, "rwsplit_server_queries", server.Total, ch, serviceID, server.ID)
+		m.createMetricForPrometheus(m.routerMetrics, "rwsplit_server_reads", server.Read, ch, serviceID, server.ID)
+		m.createMetricForPrometheus(m.routerMetrics, "rwsplit_server_writes", server.Write, ch, serviceID, server.ID)
+		m.createFloatMetricForPrometheus(m.routerMetrics, "rwsplit_server_avg_sess_duration",
+			float64(server.AvgSessDuration), ch, serviceID, server.ID)
+		m.createFloatMetricForPrometheus(m.routerMetrics, "rwsplit_server_avg_selects_per_sess",
+			server.AvgSelectsPerSession, ch, serviceID, server.ID)
+	}
 
-		primary := 0
-		if monitor.Attributes.MonitorDiagnostics.Primary {
-			primary = 1
+	return nil
+}
+
+func (m *MaxScale) parseReadConnRouteDiagnostics(serviceID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics ReadConnRouteDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.routerMetrics, "readconnroute_connections", diagnostics.Connections, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "readconnroute_current_connections", diagnostics.CurrentConnections, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "readconnroute_queries", diagnostics.Queries, ch, serviceID)
+
+	return nil
+}
+
+func (m *MaxScale) parseSchemaRouterDiagnostics(serviceID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics SchemaRouterDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.routerMetrics, "schemarouter_queries", diagnostics.Queries, ch, serviceID)
+	m.createFloatMetricForPrometheus(m.routerMetrics, "schemarouter_sescmd_percentage", diagnostics.SescmdPercentage, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "schemarouter_longest_sescmd_chain", diagnostics.LongestSescmdChain, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "schemarouter_times_sescmd_limit_exceeded", diagnostics.TimesSescmdLimitExceeded, ch, serviceID)
+	m.createFloatMetricForPrometheus(m.routerMetrics, "schemarouter_longest_session", diagnostics.LongestSession, ch, serviceID)
+	m.createFloatMetricForPrometheus(m.routerMetrics, "schemarouter_shortest_session", diagnostics.ShortestSession, ch, serviceID)
+	m.createFloatMetricForPrometheus(m.routerMetrics, "schemarouter_average_session", diagnostics.AverageSession, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "schemarouter_shard_map_hits", diagnostics.ShardMapHits, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "schemarouter_shard_map_misses", diagnostics.ShardMapMisses, ch, serviceID)
+
+	return nil
+}
+
+func (m *MaxScale) parseBinlogRouterDiagnostics(serviceID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics BinlogRouterDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.routerMetrics, "binlogrouter_info", 1, ch,
+		serviceID, diagnostics.CurrentBinlog, diagnostics.MasterState)
+
+	for domain, sequence := range gtidSequences(diagnostics.GTIDIOPos) {
+		m.createFloatMetricForPrometheus(m.routerMetrics, "binlogrouter_gtid_sequence", sequence, ch, serviceID, domain)
+	}
+
+	return nil
+}
+
+func (m *MaxScale) parseKafkaCDCDiagnostics(serviceID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics KafkaCDCDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.routerMetrics, "kafkacdc_events_sent", diagnostics.EventsSent, ch, serviceID)
+	m.createMetricForPrometheus(m.routerMetrics, "kafkacdc_errors", diagnostics.Errors, ch, serviceID)
+
+	return nil
+}
+
+// NodeName returns the node name MaxScale reported in the last scrape, empty before the
+// first successful scrape
+func (m *MaxScale) NodeName() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.nodeName
+}
+
+func (m *MaxScale) parseMaxscaleStatus(ch chan<- prometheus.Metric) error {
+	var maxscaleStatus MaxscaleStatus
+	err := m.getStatistics("/maxscale", &maxscaleStatus)
+
+	if err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_uptime",
+		maxscaleStatus.Data.Attributes.Uptime, ch)
+
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_threads",
+		maxscaleStatus.Data.Attributes.Parameters.Threads, ch)
+
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_writeq_high_water",
+		maxscaleStatus.Data.Attributes.Parameters.WriteqHighWater, ch)
+
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_writeq_low_water",
+		maxscaleStatus.Data.Attributes.Parameters.WriteqLowWater, ch)
+
+	passiveMode := 0
+	if maxscaleStatus.Data.Attributes.Parameters.Passive {
+		passiveMode = 1
+	}
+
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "status_passive", passiveMode, ch)
+
+	attributes := maxscaleStatus.Data.Attributes
+	m.nodeName = attributes.System.OS.Nodename
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "maxscale_info", 1, ch,
+		attributes.Version, attributes.Commit, attributes.System.OS.Nodename)
+
+	if startedAt, err := http.ParseTime(attributes.StartedAt); err == nil {
+		m.createMetricForPrometheus(m.maxscaleStatusMetrics, "maxscale_started_at", int(startedAt.Unix()), ch)
+	}
+
+	if activatedAt, err := http.ParseTime(attributes.ActivatedAt); err == nil {
+		m.createMetricForPrometheus(m.maxscaleStatusMetrics, "maxscale_activated_at", int(activatedAt.Unix()), ch)
+	}
+
+	// MaxScale has restarted when its uptime goes backwards
+	if m.lastUptime >= 0 && attributes.Uptime < m.lastUptime {
+		m.restarts++
+	}
+	m.lastUptime = attributes.Uptime
+	m.createMetricForPrometheus(m.maxscaleStatusMetrics, "maxscale_restarts", m.restarts, ch)
+
+	if configSync := attributes.ConfigSync; configSync != nil {
+		m.createMetricForPrometheus(m.maxscaleStatusMetrics, "config_sync_version", configSync.Version, ch)
+		m.createMetricForPrometheus(m.maxscaleStatusMetrics, "config_sync_info", 1, ch,
+			configSync.Checksum, configSync.Origin, configSync.Status)
+
+		for node, status := range configSync.Nodes {
+			m.createMetricForPrometheus(m.maxscaleStatusMetrics, "config_sync_node_status", 1, ch, node, status)
+		}
+	}
+
+	return nil
+}
+
+func (m *MaxScale) parseListeners(ch chan<- prometheus.Metric) error {
+	var listeners Listeners
+	err := m.getStatistics("/listeners", &listeners)
+	if errors.Is(err, errNotFound) {
+		listeners.Data, err = m.serviceListeners()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, listener := range listeners.Data {
+		service := ""
+		if len(listener.Relationships.Services.Data) > 0 {
+			service = listener.Relationships.Services.Data[0].ID
+		}
+		parameters := listener.Attributes.Parameters
+
+		m.createStateMetricsForPrometheus(m.listenerMetrics, "listener_state",
+			listenerStates, listener.Attributes.State, ch, listener.ID, service)
+
+		for _, service := range listener.Relationships.Services.Data {
+			m.createMetricForPrometheus(m.listenerMetrics, "listener_service", 1, ch, listener.ID, service.ID)
+		}
+
+		m.createMetricForPrometheus(m.listenerMetrics, "listener_info", 1, ch,
+			listener.ID, service, parameters.Address, strconv.Itoa(parameters.Port),
+			parameters.Protocol, parameters.Authenticator, listenerSSL(parameters.SSL))
+	}
+
+	return nil
+}
+
+// serviceListeners returns the listeners listed in the services, for MaxScale versions
+// without /v1/listeners
+func (m *MaxScale) serviceListeners() ([]Listener, error) {
+	var services Services
+	if err := m.getStatistics("/services", &services); err != nil {
+		return nil, err
+	}
+
+	var listeners []Listener
+	for _, service := range services.Data {
+		if len(service.Attributes.Listeners) == 0 {
+			continue
+		}
+		var serviceListeners []Listener
+		if err := json.Unmarshal(service.Attributes.Listeners, &serviceListeners); err != nil {
+			return nil, fmt.Errorf("could not decode the listeners of service %s: %v", service.ID, err)
+		}
+		for _, listener := range serviceListeners {
+			if len(listener.Relationships.Services.Data) == 0 {
+				listener.Relationships.Services.Data = append(listener.Relationships.Services.Data,
+					struct {
+						ID string `json:"id"`
+					}{ID: service.ID})
+			}
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners, nil
+}
+
+func (m *MaxScale) parseParameters(ch chan<- prometheus.Metric) error {
+	if !m.options.Parameters.Enabled {
+		return nil
+	}
+
+	for _, objects := range parameterObjectTypes {
+		var parameters ObjectParameters
+		err := m.getStatistics(objects.path, &parameters)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, object := range parameters.Data {
+			m.exportParameters(objects.objectType, object.ID, object.Attributes.Parameters, ch)
+		}
+	}
+
+	var maxscaleParameters MaxscaleParameters
+	if err := m.getStatistics("/maxscale", &maxscaleParameters); err != nil {
+		return err
+	}
+	m.exportParameters("maxscale", "maxscale", maxscaleParameters.Data.Attributes.Parameters, ch)
+
+	return nil
+}
+
+// parseCustomMetrics exports the metrics defined in the config file, every REST API path
+// is requested once per scrape
+func (m *MaxScale) parseCustomMetrics(ch chan<- prometheus.Metric) error {
+	var errs []error
+	responses := make(map[string][]interface{})
+
+	for _, metric := range m.options.CustomMetrics {
+		objects, ok := responses[metric.Path]
+		if !ok {
+			var response struct {
+				Data interface{} `json:"data"`
+			}
+			if err := m.getStatistics(metric.Path, &response); err != nil {
+				errs = append(errs, err)
+			} else if data, isArray := response.Data.([]interface{}); isArray {
+				objects = data
+			} else {
+				objects = []interface{}{response.Data}
+			}
+			responses[metric.Path] = objects
+		}
+
+		seen := make(map[string]bool)
+		for _, object := range objects {
+			value, ok := metric.value(object)
+			if !ok {
+				continue
+			}
+			labelValues := metric.labelValues(object)
+			key := strings.Join(labelValues, "\xff")
+			if seen[key] {
+				log.Printf("Skipping duplicate labels %v of custom metric '%s'", labelValues, metric.Name)
+				continue
+			}
+			seen[key] = true
+			m.createFloatMetricForPrometheus(m.customMetrics, metric.Name, value, ch, labelValues...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *MaxScale) exportParameters(objectType string, object string, parameters map[string]json.RawMessage,
+	ch chan<- prometheus.Metric) {
+	for parameter, data := range parameters {
+		if !m.options.Parameters.exported(parameter) {
+			continue
+		}
+		if value, ok := parameterValue(parameter, data); ok {
+			m.createFloatMetricForPrometheus(m.parameterMetrics, "parameter", value, ch, objectType, object, parameter)
+		}
+	}
+}
+
+func (m *MaxScale) parseFilters(ch chan<- prometheus.Metric) error {
+	var filters Filters
+	err := m.getStatistics("/filters", &filters)
+
+	if err != nil {
+		return err
+	}
+
+	for _, filter := range filters.Data {
+		filterID := filter.ID
+		module := filter.Attributes.Module
+
+		services := make([]string, 0, len(filter.Relationships.Services.Data))
+		for _, service := range filter.Relationships.Services.Data {
+			services = append(services, service.ID)
+		}
+		m.createMetricForPrometheus(m.filterMetrics, "filter_info", 1, ch,
+			filterID, module, strings.Join(services, ","))
+
+		// Filters without a parser only get the info metric
+		parseDiagnostics, ok := filterDiagnosticsParsers[module]
+		if ok && hasDiagnostics(filter.Attributes.FilterDiagnostics) {
+			if err := parseDiagnostics(m, filterID, filter.Attributes.FilterDiagnostics, ch); err != nil {
+				log.Printf("Could not parse filter diagnostics of filter %s: %v", filterID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// filterDiagnosticsParsers maps filter modules to the parsers of their filter diagnostics
+var filterDiagnosticsParsers = map[string]func(*MaxScale, string, json.RawMessage, chan<- prometheus.Metric) error{
+	"cache":             (*MaxScale).parseCacheDiagnostics,
+	"throttlefilter":    (*MaxScale).parseThrottleDiagnostics,
+	"tee":               (*MaxScale).parseTeeDiagnostics,
+	"namedserverfilter": (*MaxScale).parseNamedServerFilterDiagnostics,
+}
+
+func (m *MaxScale) parseCacheDiagnostics(filterID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics CacheDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.filterMetrics, "cache_hits", diagnostics.Hits, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_misses", diagnostics.Misses, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_updates", diagnostics.Updates, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_deletes", diagnostics.Deletes, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_evictions", diagnostics.Evictions, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_invalidations", diagnostics.Invalidations, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_size", diagnostics.Size, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "cache_items", diagnostics.Items, ch, filterID)
+
+	return nil
+}
+
+func (m *MaxScale) parseThrottleDiagnostics(filterID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics ThrottleDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.filterMetrics, "throttle_throttled_sessions", diagnostics.ThrottledSessions, ch, filterID)
+
+	return nil
+}
+
+func (m *MaxScale) parseTeeDiagnostics(filterID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics TeeDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.filterMetrics, "tee_branch_failures", diagnostics.BranchFailures, ch, filterID)
+
+	return nil
+}
+
+func (m *MaxScale) parseNamedServerFilterDiagnostics(filterID string, data json.RawMessage, ch chan<- prometheus.Metric) error {
+	var diagnostics NamedServerFilterDiagnostics
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		return err
+	}
+
+	m.createMetricForPrometheus(m.filterMetrics, "namedserverfilter_diverted", diagnostics.TotalDiverted, ch, filterID)
+	m.createMetricForPrometheus(m.filterMetrics, "namedserverfilter_undiverted", diagnostics.TotalUndiverted, ch, filterID)
+
+	return nil
+}
+
+func (m *MaxScale) parseMonitors(ch chan<- prometheus.Metric) error {
+	var monitors Monitors
+	err := m.getStatistics("/monitors", &monitors)
+
+	if err != nil {
+		return err
+	}
+
+	for _, monitor := range monitors.Data {
+		monitorID := monitor.ID
+		module := monitor.Attributes.Module
+		parameters := monitor.Attributes.Parameters
+
+		m.createStateMetricsForPrometheus(m.monitorMetrics, "monitor_state",
+			monitorStates, monitor.Attributes.State, ch, monitorID, module)
+
+		m.createMetricForPrometheus(m.monitorMetrics, "monitor_ticks",
+			monitor.Attributes.Ticks, ch, monitorID, module)
+
+		m.createFloatMetricForPrometheus(m.monitorMetrics, "monitor_interval",
+			float64(parameters.MonitorInterval), ch, monitorID, module)
+
+		m.createMetricForPrometheus(m.monitorMetrics, "monitor_servers",
+			len(monitor.Relationships.Servers.Data), ch, monitorID, module)
+
+		for _, server := range monitor.Relationships.Servers.Data {
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_server", 1, ch, monitorID, server.ID)
 		}
-		m.createMetricForPrometheus(m.monitorMetrics, "monitor_primary", primary, ch, monitor.ID, monitor.Attributes.Parameters.CooperativeMonitoringLocks)
 
-		auto_failover := 0
-		if monitor.Attributes.Parameters.AutoFailover {
-			auto_failover = 1
+		switch module {
+		case "mariadbmon":
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_primary",
+				boolToInt(monitor.Attributes.MonitorDiagnostics.Primary), ch, monitorID, module)
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_auto_failover",
+				boolToInt(parameters.AutoFailover), ch, monitorID, module)
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_auto_rejoin",
+				boolToInt(parameters.AutoRejoin), ch, monitorID, module)
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_failcount",
+				parameters.Failcount, ch, monitorID, module)
+			m.createFloatMetricForPrometheus(m.monitorMetrics, "monitor_switchover_timeout",
+				float64(parameters.SwitchoverTimeout), ch, monitorID, module)
+			m.createFloatMetricForPrometheus(m.monitorMetrics, "monitor_failover_timeout",
+				float64(parameters.FailoverTimeout), ch, monitorID, module)
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_enforce_read_only_slaves",
+				boolToInt(parameters.EnforceReadOnlySlaves), ch, monitorID, module)
+
+			locksHeld := 0
+			for _, server := range monitor.Attributes.MonitorDiagnostics.ServerInfo {
+				if server.LockHeld != nil && *server.LockHeld {
+					locksHeld++
+				}
+			}
+			m.createMetricForPrometheus(m.monitorMetrics, "monitor_locks_held",
+				locksHeld, ch, monitorID, module)
+
+			if parameters.CooperativeMonitoringLocks != "" {
+				m.createMetricForPrometheus(m.monitorMetrics, "monitor_cooperative_monitoring_locks_info",
+					1, ch, monitorID, module, parameters.CooperativeMonitoringLocks)
+			}
+
+			m.parseMariaDBMonitorDiagnostics(monitorID, monitor.Attributes.MonitorDiagnostics, ch)
+		case "galeramon":
+			m.parseGaleraMonitorDiagnostics(monitorID, monitor.Attributes.MonitorDiagnostics, ch)
+		}
+	}
+
+	return nil
+}
+
+func (m *MaxScale) parseMariaDBMonitorDiagnostics(monitorID string, diagnostics MonitorDiagnostics, ch chan<- prometheus.Metric) {
+	for _, server := range diagnostics.ServerInfo {
+		m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_server_read_only",
+			boolToInt(server.ReadOnly), ch, monitorID, server.Name)
+
+		for domain, sequence := range gtidSequences(server.GTIDCurrentPos) {
+			m.createFloatMetricForPrometheus(m.monitorMetrics, "mariadbmon_server_gtid_current_sequence",
+				sequence, ch, monitorID, server.Name, domain)
+		}
+
+		for domain, sequence := range gtidSequences(server.GTIDBinlogPos) {
+			m.createFloatMetricForPrometheus(m.monitorMetrics, "mariadbmon_server_gtid_binlog_sequence",
+				sequence, ch, monitorID, server.Name, domain)
+		}
+
+		// lock_held and master_group are null when not applicable
+		if server.LockHeld != nil {
+			m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_server_lock_held",
+				boolToInt(*server.LockHeld), ch, monitorID, server.Name)
+		}
+
+		if server.MasterGroup != nil {
+			m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_server_master_group",
+				*server.MasterGroup, ch, monitorID, server.Name)
+		}
+
+		for _, slave := range server.SlaveConnections {
+			master := fmt.Sprintf("%s:%d", slave.MasterHost, slave.MasterPort)
+			labelValues := []string{monitorID, server.Name, slave.ConnectionName, master}
+
+			m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_slave_io_running",
+				boolToInt(slave.SlaveIORunning == "Yes"), ch, labelValues...)
+			m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_slave_sql_running",
+				boolToInt(slave.SlaveSQLRunning == "Yes"), ch, labelValues...)
+			m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_slave_last_io_error",
+				boolToInt(slave.LastIOError != ""), ch, labelValues...)
+			m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_slave_last_sql_error",
+				boolToInt(slave.LastSQLError != ""), ch, labelValues...)
+
+			// seconds_behind_master is null while the replication is stopped
+			if slave.SecondsBehindMaster != nil {
+				m.createMetricForPrometheus(m.monitorMetrics, "mariadbmon_slave_seconds_behind_master",
+					*slave.SecondsBehindMaster, ch, labelValues...)
+			}
+		}
+	}
+}
+
+func (m *MaxScale) parseGaleraMonitorDiagnostics(monitorID string, diagnostics MonitorDiagnostics, ch chan<- prometheus.Metric) {
+	m.createMetricForPrometheus(m.monitorMetrics, "galeramon_root_node_as_master",
+		boolToInt(diagnostics.RootNodeAsMaster), ch, monitorID)
+	m.createMetricForPrometheus(m.monitorMetrics, "galeramon_use_priority",
+		boolToInt(diagnostics.UsePriority), ch, monitorID)
+	m.createMetricForPrometheus(m.monitorMetrics, "galeramon_disable_master_failback",
+		boolToInt(diagnostics.DisableMasterFailback), ch, monitorID)
+	m.createMetricForPrometheus(m.monitorMetrics, "galeramon_disable_master_role_setting",
+		boolToInt(diagnostics.DisableMasterRoleSetting), ch, monitorID)
+
+	// The cluster UUID seen by most of the nodes is taken as the one of the cluster. A node
+	// with a different UUID is in a separate cluster, e.g. after a split-brain.
+	uuidCounts := make(map[string]int)
+	for _, server := range diagnostics.ServerInfo {
+		if server.ClusterUUID != "" {
+			uuidCounts[server.ClusterUUID]++
+		}
+	}
+	clusterUUID := ""
+	for uuid, count := range uuidCounts {
+		if count > uuidCounts[clusterUUID] || (count == uuidCounts[clusterUUID] && uuid < clusterUUID) {
+			clusterUUID = uuid
+		}
+	}
+
+	for _, server := range diagnostics.ServerInfo {
+		m.createMetricForPrometheus(m.monitorMetrics, "galeramon_server_joined",
+			boolToInt(server.Joined), ch, monitorID, server.Name)
+		m.createMetricForPrometheus(m.monitorMetrics, "galeramon_server_local_index",
+			server.LocalIndex, ch, monitorID, server.Name)
+		m.createMetricForPrometheus(m.monitorMetrics, "galeramon_server_local_state",
+			server.LocalState, ch, monitorID, server.Name)
+		m.createMetricForPrometheus(m.monitorMetrics, "galeramon_server_cluster_size",
+			server.ClusterSize, ch, monitorID, server.Name)
+
+		if server.ClusterUUID != "" {
+			m.createMetricForPrometheus(m.monitorMetrics, "galeramon_server_cluster_info",
+				1, ch, monitorID, server.Name, server.ClusterUUID)
+		}
+
+		m.createMetricForPrometheus(m.monitorMetrics, "galeramon_server_cluster_uuid_mismatch",
+			boolToInt(server.ClusterUUID != "" && server.ClusterUUID != clusterUUID), ch, monitorID, server.Name)
+	}
+}
+
+func (m *MaxScale) parseModules(ch chan<- prometheus.Metric) error {
+	var modules Modules
+	err := m.getStatistics("/maxscale/modules", &modules)
+
+	if err != nil {
+		return err
+	}
+
+	for _, module := range modules.Data {
+		m.createMetricForPrometheus(m.moduleMetrics, "module_info", 1, ch, module.ID,
+			module.Attributes.ModuleType, module.Attributes.Version, module.Attributes.Maturity, module.Attributes.API)
+	}
+
+	return nil
+}
+
+func (m *MaxScale) parseMemory(ch chan<- prometheus.Metric) error {
+	var memoryStatus MemoryStatus
+	err := m.getStatistics("/maxscale/memory", &memoryStatus)
+
+	// Memory accounting is only available in newer MaxScale versions
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	memory := memoryStatus.Data.Attributes.Memory
+	m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_query_classifier", memory.QueryClassifier, ch)
+	m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_zombies", memory.Zombies, ch)
+	m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_sessions", memory.Sessions, ch)
+	m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_total", memory.Total, ch)
+
+	return nil
+}
+
+func (m *MaxScale) parseQueryClassifier(ch chan<- prometheus.Metric) error {
+	var queryClassifier QueryClassifier
+	err := m.getStatistics("/maxscale/query_classifier", &queryClassifier)
+
+	// The query classifier resources are only available in newer MaxScale versions
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	attributes := queryClassifier.Data.Attributes
+	m.createMetricForPrometheus(m.qcMetrics, "query_classifier_info", 1, ch, attributes.Module)
+	m.createMetricForPrometheus(m.qcMetrics, "query_classifier_cache_size",
+		attributes.Parameters.CacheSize, ch)
+
+	if m.options.QueryClassifierTopStatements <= 0 {
+		return nil
+	}
+
+	var cache QueryClassifierCache
+	err = m.getStatistics("/maxscale/query_classifier/cache", &cache)
+
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the statements with the most hits are exported to keep the cardinality bounded
+	statements := cache.Data
+	sort.Slice(statements, func(i, j int) bool {
+		if statements[i].Attributes.Hits != statements[j].Attributes.Hits {
+			return statements[i].Attributes.Hits > statements[j].Attributes.Hits
+		}
+		return statements[i].ID < statements[j].ID
+	})
+	if len(statements) > m.options.QueryClassifierTopStatements {
+		statements = statements[:m.options.QueryClassifierTopStatements]
+	}
+
+	for _, statement := range statements {
+		m.createMetricForPrometheus(m.qcMetrics, "query_classifier_statement_hits",
+			statement.Attributes.Hits, ch, statementLabel(statement.ID))
+	}
+
+	return nil
+}
+
+// statementLabel shortens a statement to maxStatementLabelLength bytes for its label value.
+// Shortened statements end with a hash of the whole statement to keep them unique.
+func statementLabel(statement string) string {
+	if len(statement) <= maxStatementLabelLength {
+		return statement
+	}
+
+	hash := sha256.Sum256([]byte(statement))
+	suffix := "... " + hex.EncodeToString(hash[:4])
+	end := maxStatementLabelLength - len(suffix)
+	for end > 0 && !utf8.RuneStart(statement[end]) {
+		end--
+	}
+	return statement[:end] + suffix
+}
+
+func (m *MaxScale) parseSessions(ch chan<- prometheus.Metric) error {
+	rules := m.options.Sessions.Thresholds.rules()
+	if !m.options.Sessions.Enabled && len(rules) == 0 {
+		return nil
+	}
+
+	var sessions Sessions
+	err := m.getStatistics("/sessions", &sessions)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if len(rules) > 0 {
+		m.detectStuckSessions(sessions, rules, now, ch)
+	}
+
+	if !m.options.Sessions.Enabled {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	countLabels := make(map[string][]string)
+	ages := make(map[string][]float64)
+	idleTimes := make(map[string][]float64)
+
+	for _, session := range sessions.Data {
+		service := session.service()
+
+		// Sessions are only counted per aggregation, never exported one by one
+		labelValues := make([]string, 0, len(m.options.Sessions.Labels))
+		for _, label := range m.options.Sessions.Labels {
+			switch label {
+			case "service":
+				labelValues = append(labelValues, service)
+			case "user":
+				labelValues = append(labelValues, session.Attributes.User)
+			case "remote":
+				labelValues = append(labelValues, session.Attributes.Remote)
+			}
 		}
-		m.createMetricForPrometheus(m.monitorMetrics, "monitor_auto_failover", auto_failover, ch, monitor.ID, monitor.Attributes.Parameters.CooperativeMonitoringLocks)
+		key := strings.Join(labelValues, "\x00")
+		counts[key]++
+		countLabels[key] = labelValues
 
-		auto_rejoin := 0
-		if monitor.Attributes.Parameters.AutoFailover {
-			auto_rejoin = 1
+		if age, ok := session.age(now); ok {
+			ages[service] = append(ages[service], age)
 		}
-		m.createMetricForPrometheus(m.monitorMetrics, "monitor_auto_rejoin", auto_rejoin, ch, monitor.ID, monitor.Attributes.Parameters.CooperativeMonitoringLocks)
+		idleTimes[service] = append(idleTimes[service], session.Attributes.Idle)
+	}
 
+	for key, count := range counts {
+		m.createMetricForPrometheus(m.sessionMetrics, "sessions", count, ch, countLabels[key]...)
+	}
+
+	for service, values := range ages {
+		m.createHistogramForPrometheus(m.sessionMetrics, "session_age",
+			m.options.Sessions.AgeBuckets, values, ch, service)
+	}
+
+	for service, values := range idleTimes {
+		m.createHistogramForPrometheus(m.sessionMetrics, "session_idle",
+			m.options.Sessions.IdleBuckets, values, ch, service)
 	}
 
 	return nil
 }
 
+// detectStuckSessions counts the sessions exceeding the configured thresholds per service
+// and exports the age of the oldest of them.
+func (m *MaxScale) detectStuckSessions(sessions Sessions, rules []string, now time.Time, ch chan<- prometheus.Metric) {
+	thresholds := m.options.Sessions.Thresholds
+	counts := make(map[string]map[string]int)
+	oldest := make(map[string]float64)
+
+	for _, session := range sessions.Data {
+		service := session.service()
+		if _, ok := counts[service]; !ok {
+			counts[service] = make(map[string]int)
+			oldest[service] = 0
+		}
+
+		age, _ := session.age(now)
+		pending := session.pendingQueries()
+
+		var exceeded []string
+		if thresholds.IdleInTransaction > 0 && pending == 0 && session.inTransaction() &&
+			session.Attributes.Idle >= thresholds.IdleInTransaction {
+			exceeded = append(exceeded, "idle_in_transaction")
+		}
+		if thresholds.Age > 0 && age >= thresholds.Age {
+			exceeded = append(exceeded, "age")
+		}
+		if thresholds.PendingQueries > 0 && pending >= thresholds.PendingQueries {
+			exceeded = append(exceeded, "pending_queries")
+		}
+
+		if len(exceeded) == 0 {
+			continue
+		}
+
+		for _, rule := range exceeded {
+			counts[service][rule]++
+		}
+		if age > oldest[service] {
+			oldest[service] = age
+		}
+
+		if m.options.Sessions.LogOffendingSessions {
+			statements := make([]string, 0, len(session.Attributes.Queries))
+			for _, query := range session.Attributes.Queries {
+				statements = append(statements, query.Statement)
+			}
+			log.Printf("Warning: session %s of service %s (user %s@%s) exceeds the thresholds %v, last statements: %q",
+				session.ID, service, session.Attributes.User, session.Attributes.Remote, exceeded, statements)
+		}
+	}
+
+	for service, serviceCounts := range counts {
+		for _, rule := range rules {
+			m.createMetricForPrometheus(m.sessionMetrics, "sessions_over_threshold",
+				serviceCounts[rule], ch, service, rule)
+		}
+		m.createFloatMetricForPrometheus(m.sessionMetrics, "sessions_oldest_offending_age",
+			oldest[service], ch, service)
+	}
+}
+
 func (m *MaxScale) parseThreadStatus(ch chan<- prometheus.Metric) error {
 	var threadStatus ThreadStatus
 	err := m.getStatistics("/maxscale/threads", &threadStatus)
@@ -347,44 +1850,73 @@ func (m *MaxScale) parseThreadStatus(ch chan<- prometheus.Metric) error {
 	}
 
 	for _, threadStatus := range threadStatus.Data {
-		m.createMetricForPrometheus(m.statusMetrics, "status_read_events",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_read_events",
 			threadStatus.Attributes.Stats.Reads, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_write_events",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_write_events",
 			threadStatus.Attributes.Stats.Writes, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_error_events",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_error_events",
 			threadStatus.Attributes.Stats.Errors, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_hangup_events",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_hangup_events",
 			threadStatus.Attributes.Stats.Hangups, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_accept_events",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_accept_events",
 			threadStatus.Attributes.Stats.Accepts, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_avg_event_queue_length",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_avg_event_queue_length",
 			threadStatus.Attributes.Stats.AvgEventQueueLength, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_max_event_queue_length",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_max_event_queue_length",
 			threadStatus.Attributes.Stats.MaxEventQueueLength, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_max_event_exec_time",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_max_event_exec_time",
 			threadStatus.Attributes.Stats.MaxExecTime, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_max_event_queue_time",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_max_event_queue_time",
 			threadStatus.Attributes.Stats.MaxQueueTime, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_current_descriptors",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_current_descriptors",
 			threadStatus.Attributes.Stats.CurrentDescriptors, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_total_descriptors",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_total_descriptors",
 			threadStatus.Attributes.Stats.TotalDescriptors, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_load_last_second",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_load_last_second",
 			threadStatus.Attributes.Stats.Load.LastSecond, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_load_last_minute",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_load_last_minute",
 			threadStatus.Attributes.Stats.Load.LastMinute, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_load_last_hour",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_load_last_hour",
 			threadStatus.Attributes.Stats.Load.LastHour, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_size",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_size",
 			threadStatus.Attributes.Stats.QueryClassifierCache.Size, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_inserts",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_inserts",
 			threadStatus.Attributes.Stats.QueryClassifierCache.Inserts, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_hits",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_hits",
 			threadStatus.Attributes.Stats.QueryClassifierCache.Hits, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_misses",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_misses",
 			threadStatus.Attributes.Stats.QueryClassifierCache.Misses, ch, threadStatus.ID)
-		m.createMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_evictions",
+		m.createFloatMetricForPrometheus(m.statusMetrics, "status_query_classifier_cache_evictions",
 			threadStatus.Attributes.Stats.QueryClassifierCache.Evictions, ch, threadStatus.ID)
+
+		// The session counts and the listening flag are only reported by newer MaxScale versions
+		if sessions := threadStatus.Attributes.Stats.Sessions; sessions != nil {
+			m.createFloatMetricForPrometheus(m.statusMetrics, "status_sessions", *sessions, ch, threadStatus.ID)
+		}
+		if zombies := threadStatus.Attributes.Stats.Zombies; zombies != nil {
+			m.createFloatMetricForPrometheus(m.statusMetrics, "status_zombies", *zombies, ch, threadStatus.ID)
+		}
+		if listening := threadStatus.Attributes.Stats.Listening; listening != nil {
+			m.createMetricForPrometheus(m.statusMetrics, "status_listening", boolToInt(*listening), ch, threadStatus.ID)
+		}
+
+		// The worker state is only reported by newer MaxScale versions
+		if threadStatus.Attributes.Stats.State != "" {
+			m.createStateMetricsForPrometheus(m.statusMetrics, "status_state",
+				threadStates, threadStatus.Attributes.Stats.State, ch, threadStatus.ID)
+		}
+
+		// Per thread memory accounting is only reported by newer MaxScale versions
+		if memory := threadStatus.Attributes.Stats.Memory; memory.Total > 0 {
+			m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_thread_query_classifier",
+				memory.QueryClassifier, ch, threadStatus.ID)
+			m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_thread_zombies",
+				memory.Zombies, ch, threadStatus.ID)
+			m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_thread_sessions",
+				memory.Sessions, ch, threadStatus.ID)
+			m.createFloatMetricForPrometheus(m.memoryMetrics, "memory_thread_total",
+				memory.Total, ch, threadStatus.ID)
+		}
 	}
 
 	return nil
@@ -399,6 +1931,23 @@ func GetEnvVar(envName string, defaultValue string) string {
 	return envVal
 }
 
+// validateConfig checks the settings taken from the environment variables and the
+// config file alike
+func validateConfig() error {
+	for label := range constLabels {
+		if !labelRegexp.MatchString(label) || label == nodeNameLabel {
+			return fmt.Errorf("invalid label name '%s'", label)
+		}
+	}
+	if nodeNameLabel != "" && !labelRegexp.MatchString(nodeNameLabel) {
+		return fmt.Errorf("invalid node name label '%s'", nodeNameLabel)
+	}
+	if !metricNameRegexp.MatchString(metricNamespace) {
+		return fmt.Errorf("invalid namespace '%s'", metricNamespace)
+	}
+	return nil
+}
+
 func readConfigFile(fname string) {
 	yamlFile, err := os.ReadFile(fname)
 	if err != nil {
@@ -413,7 +1962,8 @@ func readConfigFile(fname string) {
 }
 
 func parseConfigFile(contents []byte) {
-	var config ConfigValues
+	// Options missing from the file keep their current values
+	config := ConfigValues{Options: exporterOptions}
 	err := yaml.Unmarshal(contents, &config)
 	if err != nil {
 		log.Fatalf("Could not parse config file contents: %v", err)
@@ -434,6 +1984,98 @@ func parseConfigFile(contents []byte) {
 		maxScaleCACertificate = config.CACertificate
 	}
 	maxScaleTLSInsecureSkipVerify = config.TLSInsecureSkipVerify
+	if config.Labels != nil {
+		constLabels = config.Labels
+	}
+	if config.NodeNameLabel != "" {
+		nodeNameLabel = config.NodeNameLabel
+	}
+	if config.Namespace != "" {
+		metricNamespace = config.Namespace
+	}
+	if config.RelabelConfigs != nil {
+		relabelConfigs = config.RelabelConfigs
+	}
+	for i := range relabelConfigs {
+		if err := relabelConfigs[i].compile(); err != nil {
+			log.Fatalf("Invalid metric relabel config %d: %v", i+1, err)
+		}
+	}
+
+	for _, label := range config.Options.Sessions.Labels {
+		if !contains(sessionLabels, label) {
+			log.Fatalf("Unknown session label '%s', expected any of %v", label, sessionLabels)
+		}
+	}
+	for _, parameter := range config.Options.ServerLabels.Parameters {
+		if !contains(serverParameterLabels, parameter) {
+			log.Fatalf("Unknown server label parameter '%s', expected any of %v", parameter, serverParameterLabels)
+		}
+	}
+	for i, rule := range config.Options.ServerLabels.Rules {
+		for label := range rule.Labels {
+			if !labelRegexp.MatchString(label) || contains(serverUpLabelNames, label) || contains(serverEventLabelNames, label) {
+				log.Fatalf("Invalid server label name '%s'", label)
+			}
+		}
+		if rule.Address != "" {
+			address, err := regexp.Compile(rule.Address)
+			if err != nil {
+				log.Fatalf("Could not compile server address regular expression '%s': %v", rule.Address, err)
+			}
+			config.Options.ServerLabels.Rules[i].address = address
+		}
+	}
+	if config.Options.Parameters.Allow != "" {
+		if config.Options.Parameters.allow, err = regexp.Compile(config.Options.Parameters.Allow); err != nil {
+			log.Fatalf("Could not compile parameter allow regular expression '%s': %v", config.Options.Parameters.Allow, err)
+		}
+	}
+	if config.Options.Parameters.Deny != "" {
+		if config.Options.Parameters.deny, err = regexp.Compile(config.Options.Parameters.Deny); err != nil {
+			log.Fatalf("Could not compile parameter deny regular expression '%s': %v", config.Options.Parameters.Deny, err)
+		}
+	}
+	names := make(map[string]bool)
+	for _, metric := range config.Options.CustomMetrics {
+		if !metricNameRegexp.MatchString(metric.Name) || names[metric.Name] {
+			log.Fatalf("Invalid or duplicate custom metric name '%s'", metric.Name)
+		}
+		names[metric.Name] = true
+		if !strings.HasPrefix(metric.Path, "/") || metric.Value == "" {
+			log.Fatalf("Custom metric '%s' needs a path starting with / and a value", metric.Name)
+		}
+		if metric.Type != "" && metric.Type != "gauge" && metric.Type != "counter" {
+			log.Fatalf("Unknown type '%s' of custom metric '%s', expected gauge or counter", metric.Type, metric.Name)
+		}
+		for label := range metric.Labels {
+			if !labelRegexp.MatchString(label) {
+				log.Fatalf("Invalid label name '%s' of custom metric '%s'", label, metric.Name)
+			}
+		}
+	}
+	for collector, limit := range config.Options.SeriesLimits {
+		if !knownCollector(collector) || limit < 0 {
+			log.Fatalf("Invalid series limit %d of collector '%s'", limit, collector)
+		}
+	}
+	for collector, interval := range config.Options.RefreshIntervals {
+		if !knownCollector(collector) || interval < 0 {
+			log.Fatalf("Invalid refresh interval %v of collector '%s'", interval, collector)
+		}
+	}
+	sort.Float64s(config.Options.Sessions.AgeBuckets)
+	sort.Float64s(config.Options.Sessions.IdleBuckets)
+	exporterOptions = config.Options
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 func setConfigFromEnvironmentVars() {
@@ -447,22 +2089,65 @@ func setConfigFromEnvironmentVars() {
 	}
 	maxScaleCACertificate = GetEnvVar("MAXSCALE_CA_CERTIFICATE", "")
 	maxctrlExporterConfigFile = GetEnvVar("MAXCTRL_EXPORTER_CFG_FILE", "maxctrl_exporter.yaml")
+	constLabels = map[string]string{}
+	nodeNameLabel = GetEnvVar("MAXSCALE_NODE_NAME_LABEL", "")
+	metricNamespace = GetEnvVar("MAXSCALE_EXPORTER_NAMESPACE", Namespace)
+	relabelConfigs = nil
+	if exporterOptions.QueryClassifierTopStatements, err = strconv.Atoi(GetEnvVar("MAXSCALE_QC_TOP_STATEMENTS", "0")); err != nil {
+		exporterOptions.QueryClassifierTopStatements = 0
+	}
+	exporterOptions.Sessions = SessionsOptions{
+		Labels:      sessionLabels,
+		AgeBuckets:  []float64{1, 10, 60, 300, 900, 3600, 14400, 86400},
+		IdleBuckets: []float64{1, 5, 10, 30, 60, 300, 900, 3600},
+	}
+	if exporterOptions.Sessions.Enabled, err = strconv.ParseBool(GetEnvVar("MAXSCALE_SESSIONS_ENABLED", "false")); err != nil {
+		exporterOptions.Sessions.Enabled = false
+	}
+	exporterOptions.ServerLabels = ServerLabelsOptions{}
+	exporterOptions.Parameters = ParametersOptions{}
+	exporterOptions.CustomMetrics = nil
+	exporterOptions.SeriesLimits = nil
+	exporterOptions.RefreshIntervals = nil
+	if exporterOptions.Parameters.Enabled, err = strconv.ParseBool(GetEnvVar("MAXSCALE_PARAMETERS_ENABLED", "false")); err != nil {
+		exporterOptions.Parameters.Enabled = false
+	}
 }
 
 func main() {
 	setConfigFromEnvironmentVars()
 	readConfigFile(maxctrlExporterConfigFile)
+	if err := validateConfig(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	log.Print("Starting MaxScale exporter")
 	log.Printf("Scraping MaxScale JSON API at: %s", maxScaleUrl)
 
-	exporter, err := NewExporter(maxScaleUrl, maxScaleUsername, maxScalePassword, maxScaleCACertificate, maxScaleTLSInsecureSkipVerify)
+	exporter, err := NewExporter(maxScaleUrl, maxScaleUsername, maxScalePassword, maxScaleCACertificate, maxScaleTLSInsecureSkipVerify,
+		exporterOptions)
 	if err != nil {
 		log.Fatalf("Failed to start maxscale exporter: %v\n", err)
 	}
 
-	prometheus.MustRegister(exporter)
-	http.Handle(metricsPath, promhttp.Handler())
+	labels := prometheus.Labels{}
+	labelNames := []string{}
+	for label, value := range constLabels {
+		labels[label] = value
+		labelNames = append(labelNames, label)
+	}
+	if nodeNameLabel != "" {
+		labelNames = append(labelNames, nodeNameLabel)
+	}
+	if err := exporter.checkLabelNames(labelNames); err != nil {
+		log.Fatalf("Invalid labels: %v", err)
+	}
+
+	prometheus.WrapRegistererWith(labels, prometheus.DefaultRegisterer).MustRegister(exporter)
+	gatherer := relabelingGatherer{gatherer: prometheus.DefaultGatherer, namespace: metricNamespace, configs: relabelConfigs,
+		nodeNameLabel: nodeNameLabel, nodeName: exporter.NodeName}
+	http.Handle(metricsPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte(`<html>
 			<head><title>MaxScale Exporter</title></head>
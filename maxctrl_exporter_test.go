@@ -14,10 +14,22 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // Verify GetEnvVar does what it says on the tin
@@ -207,3 +219,945 @@ func TestConfigParsing(t *testing.T) {
 		log.Fatalf("Config key 'MAXSCALE_TLS_INSECURE_SKIP_VERIFY' had unexpected value. wanted 'false' and got '%v'", maxScaleTLSInsecureSkipVerify)
 	}
 }
+
+// Verify MaxScale duration strings are converted into seconds
+func TestParseDuration(t *testing.T) {
+	want := map[string]float64{
+		"":       0,
+		"15":     15,
+		"0ns":    0,
+		"250ms":  0.25,
+		"1.5s":   1.5,
+		"2min":   120,
+		"1h":     3600,
+		" 10s  ": 10,
+	}
+
+	for value, seconds := range want {
+		got, err := parseDuration(value)
+		if err != nil {
+			t.Fatalf("Could not parse duration '%s': %v", value, err)
+		}
+		if got != seconds {
+			t.Fatalf("Duration '%s' had unexpected value. wanted '%v' and got '%v'", value, seconds, got)
+		}
+	}
+
+	if _, err := parseDuration("forever"); err == nil {
+		t.Fatalf("Duration 'forever' was parsed without an error")
+	}
+}
+
+// Verify durations without unit are interpreted in the unit of the parameter
+func TestDurationUnits(t *testing.T) {
+	var parameters struct {
+		MonitorInterval   MillisecondDuration `json:"monitor_interval"`
+		SwitchoverTimeout Duration            `json:"switchover_timeout"`
+		FailoverTimeout   Duration            `json:"failover_timeout"`
+	}
+
+	err := json.Unmarshal([]byte(`{"monitor_interval": 2000, "switchover_timeout": "90", "failover_timeout": "90000ms"}`), &parameters)
+	if err != nil {
+		t.Fatalf("Could not decode durations: %v", err)
+	}
+
+	if parameters.MonitorInterval != 2 {
+		t.Fatalf("monitor_interval had unexpected value. wanted '2' and got '%v'", parameters.MonitorInterval)
+	}
+	if parameters.SwitchoverTimeout != 90 {
+		t.Fatalf("switchover_timeout had unexpected value. wanted '90' and got '%v'", parameters.SwitchoverTimeout)
+	}
+	if parameters.FailoverTimeout != 90 {
+		t.Fatalf("failover_timeout had unexpected value. wanted '90' and got '%v'", parameters.FailoverTimeout)
+	}
+}
+
+// Verify parameter values are converted into numbers, durations into seconds and sizes into bytes
+func TestParameterValue(t *testing.T) {
+	want := map[string]float64{
+		`3306`:      3306,
+		`"100"`:     100,
+		`true`:      1,
+		`false`:     0,
+		`"90000ms"`: 90,
+		`"5min"`:    300,
+		`"5m"`:      300,
+		`"1.5s"`:    1.5,
+		`"1h"`:      3600,
+		`"16k"`:     16000,
+		`"1Gi"`:     1 << 30,
+		`"2Mi"`:     2 << 20,
+	}
+
+	for data, value := range want {
+		got, ok := parameterValue("max_connections", json.RawMessage(data))
+		if !ok || got != value {
+			t.Fatalf("Parameter %s had unexpected value. wanted '%v' and got '%v'", data, value, got)
+		}
+	}
+
+	// Durations without unit are milliseconds for these parameters
+	for data, value := range map[string]float64{`2000`: 2, `"2000"`: 2, `"2s"`: 2, `"1m"`: 60} {
+		got, ok := parameterValue("monitor_interval", json.RawMessage(data))
+		if !ok || got != value {
+			t.Fatalf("Parameter monitor_interval %s had unexpected value. wanted '%v' and got '%v'", data, value, got)
+		}
+	}
+
+	for _, data := range []string{`"127.0.0.1"`, `null`, `"primary"`, `[]`} {
+		if _, ok := parameterValue("max_connections", json.RawMessage(data)); ok {
+			t.Fatalf("Parameter %s was unexpectedly converted", data)
+		}
+	}
+	if _, ok := parameterValue("monitor_interval", json.RawMessage(`null`)); ok {
+		t.Fatalf("Parameter monitor_interval null was unexpectedly converted")
+	}
+}
+
+// Verify custom metrics select their value and labels and map values
+func TestCustomMetric(t *testing.T) {
+	var object interface{}
+	err := json.Unmarshal([]byte(`{"id": "server1", "attributes": {"state": "Master, Running", "statistics": {"connections": 3}},
+		"relationships": {"monitors": {"data": [{"id": "MariaDB-Monitor"}]}}}`), &object)
+	if err != nil {
+		t.Fatalf("Could not decode object: %v", err)
+	}
+
+	metric := CustomMetric{
+		Value:        "attributes.state",
+		Labels:       map[string]string{"server": "id", "monitor": "relationships.monitors.data[0].id", "missing": "attributes.missing"},
+		ValueMapping: map[string]float64{"Master, Running": 2},
+	}
+	if value, ok := metric.value(object); !ok || value != 2 {
+		t.Fatalf("Mapped value had unexpected value. wanted '2' and got '%v'", value)
+	}
+	if got := strings.Join(metric.labelValues(object), ","); got != ",MariaDB-Monitor,server1" {
+		t.Fatalf("Labels had unexpected value. wanted ',MariaDB-Monitor,server1' and got '%v'", got)
+	}
+
+	metric.Value = "$.attributes.statistics.connections"
+	if value, ok := metric.value(object); !ok || value != 3 {
+		t.Fatalf("Value had unexpected value. wanted '3' and got '%v'", value)
+	}
+
+	metric.Value = "attributes.statistics.missing"
+	if _, ok := metric.value(object); ok {
+		t.Fatalf("Missing value was unexpectedly selected")
+	}
+}
+
+// Verify custom metrics with the name of a built-in metric are rejected
+func TestCustomMetricNames(t *testing.T) {
+	for name, valid := range map[string]bool{"server_persistent_connections": true, "server_connections": false,
+		"status_uptime": false, "parameter": false, "up": false, "exporter_total_scrapes": false} {
+		options := ExporterOptions{CustomMetrics: []CustomMetric{{Name: name, Path: "/servers", Value: "id"}}}
+		_, err := NewExporter("http://127.0.0.1:0", "", "", "", false, options)
+		if (err == nil) != valid {
+			t.Errorf("Custom metric %s had unexpected result. wanted valid %v and got error %v", name, valid, err)
+		}
+	}
+}
+
+// Verify the ssl parameter of listeners is normalised across MaxScale versions
+func TestListenerSSL(t *testing.T) {
+	want := map[string]string{
+		`true`:       "true",
+		`false`:      "false",
+		`"required"`: "true",
+		`"disabled"`: "false",
+		`null`:       "false",
+		``:           "false",
+	}
+
+	for data, ssl := range want {
+		if got := listenerSSL(json.RawMessage(data)); got != ssl {
+			t.Fatalf("Listener ssl %s had unexpected value. wanted '%v' and got '%v'", data, ssl, got)
+		}
+	}
+}
+
+// Verify options missing from the config file keep the values from the environment
+func TestOptionsParsing(t *testing.T) {
+	setConfigFromEnvironmentVars()
+	if exporterOptions.QueryClassifierTopStatements != 0 {
+		t.Fatalf("Option 'query_classifier_top_statements' had unexpected default. wanted '0' and got '%v'", exporterOptions.QueryClassifierTopStatements)
+	}
+
+	os.Setenv("MAXSCALE_QC_TOP_STATEMENTS", "25")
+	defer os.Unsetenv("MAXSCALE_QC_TOP_STATEMENTS")
+
+	setConfigFromEnvironmentVars()
+	parseConfigFile([]byte("url: http://10.10.10.1:8989\n"))
+
+	if exporterOptions.QueryClassifierTopStatements != 25 {
+		t.Fatalf("Option 'query_classifier_top_statements' had unexpected value. wanted '25' and got '%v'", exporterOptions.QueryClassifierTopStatements)
+	}
+
+	parseConfigFile([]byte("query_classifier_top_statements: 0\n"))
+
+	if exporterOptions.QueryClassifierTopStatements != 0 {
+		t.Fatalf("Option 'query_classifier_top_statements' had unexpected value. wanted '0' and got '%v'", exporterOptions.QueryClassifierTopStatements)
+	}
+}
+
+// Verify open transactions are recognized from the last statements of a session
+func TestSessionInTransaction(t *testing.T) {
+	want := map[string]bool{
+		`[]`: false,
+		`[{"statement": "BEGIN"}, {"statement": "UPDATE t SET a = 1"}]`:                      true,
+		`[{"statement": "start transaction"}, {"statement": "COMMIT"}]`:                      false,
+		`[{"statement": "BEGIN"}, {"statement": "ROLLBACK TO SAVEPOINT s1"}]`:                true,
+		`[{"statement": "BEGIN"}, {"statement": "ROLLBACK"}, {"statement": "SELECT 1"}]`:     false,
+		`[{"statement": "COMMIT"}, {"statement": " begin work"}, {"statement": "SELECT 1"}]`: true,
+	}
+
+	for queries, inTransaction := range want {
+		var session Session
+		if err := json.Unmarshal([]byte(`{"attributes": {"queries": `+queries+`}}`), &session); err != nil {
+			t.Fatalf("Could not decode session: %v", err)
+		}
+		if got := session.inTransaction(); got != inTransaction {
+			t.Fatalf("Statements %s had unexpected transaction state. wanted '%v' and got '%v'", queries, inTransaction, got)
+		}
+	}
+}
+
+// Verify the extra server labels of parameters and matching rules
+func TestServerLabels(t *testing.T) {
+	setConfigFromEnvironmentVars()
+	parseConfigFile([]byte(`
+server_labels:
+  parameters: [monitor]
+  rules:
+    - labels: {datacenter: unknown}
+    - servers: [server1]
+      labels: {datacenter: dc1, rack: r1}
+    - address: "^10\\.2\\."
+      labels: {datacenter: dc2}
+`))
+
+	options := exporterOptions.ServerLabels
+	if got := strings.Join(options.labelNames(), ","); got != "monitor,datacenter,rack" {
+		t.Fatalf("Server label names had unexpected value. wanted 'monitor,datacenter,rack' and got '%v'", got)
+	}
+
+	want := map[string]string{
+		"server1/10.1.0.1": "mon,dc1,r1",
+		"server2/10.2.0.1": "mon,dc2,",
+		"server3/10.3.0.1": "mon,unknown,",
+	}
+	for server, labels := range want {
+		name, address, _ := strings.Cut(server, "/")
+		got := strings.Join(options.labelValues(name, address, map[string]string{"monitor": "mon"}), ",")
+		if got != labels {
+			t.Fatalf("Server %s had unexpected labels. wanted '%v' and got '%v'", server, labels, got)
+		}
+	}
+}
+
+// Verify the labels added to every metric are read from the config file
+func TestConstLabels(t *testing.T) {
+	os.Setenv("MAXSCALE_NODE_NAME_LABEL", "node")
+	defer os.Unsetenv("MAXSCALE_NODE_NAME_LABEL")
+
+	setConfigFromEnvironmentVars()
+	parseConfigFile([]byte("labels:\n  cluster: billing\n"))
+
+	if constLabels["cluster"] != "billing" || len(constLabels) != 1 {
+		t.Fatalf("Labels had unexpected value. wanted 'map[cluster:billing]' and got '%v'", constLabels)
+	}
+	if nodeNameLabel != "node" {
+		t.Fatalf("Node name label had unexpected value. wanted 'node' and got '%v'", nodeNameLabel)
+	}
+}
+
+// Verify an invalid namespace is rejected also without a config file
+func TestValidateNamespace(t *testing.T) {
+	for namespace, valid := range map[string]bool{"maxscale": true, "my_ns": true, "my-ns": false, "1ns": false} {
+		os.Setenv("MAXSCALE_EXPORTER_NAMESPACE", namespace)
+		setConfigFromEnvironmentVars()
+		readConfigFile("/nonexistent/maxctrl_exporter.yaml")
+		if err := validateConfig(); (err == nil) != valid {
+			t.Errorf("Namespace %s had unexpected result. wanted valid %v and got error %v", namespace, valid, err)
+		}
+	}
+	os.Unsetenv("MAXSCALE_EXPORTER_NAMESPACE")
+	setConfigFromEnvironmentVars()
+}
+
+// Verify an invalid node name label is rejected also without a config file
+func TestValidateNodeNameLabel(t *testing.T) {
+	for label, valid := range map[string]bool{"": true, "maxscale_node": true, "maxscale-node": false, "node name": false} {
+		os.Setenv("MAXSCALE_NODE_NAME_LABEL", label)
+		setConfigFromEnvironmentVars()
+		readConfigFile("/nonexistent/maxctrl_exporter.yaml")
+		if err := validateConfig(); (err == nil) != valid {
+			t.Errorf("Node name label %s had unexpected result. wanted valid %v and got error %v", label, valid, err)
+		}
+	}
+	os.Unsetenv("MAXSCALE_NODE_NAME_LABEL")
+	setConfigFromEnvironmentVars()
+}
+
+// Verify exporter labels colliding with metric labels are rejected
+func TestCheckLabelNames(t *testing.T) {
+	exporter := newTestExporter(t, ExporterOptions{
+		ServerLabels:  ServerLabelsOptions{Parameters: []string{"rack"}},
+		CustomMetrics: []CustomMetric{{Name: "service_users", Path: "/services", Value: "attributes.users", Labels: map[string]string{"tenant": "id"}}},
+	})
+
+	for labels, valid := range map[string]bool{"cluster,env": true, "cluster,server": false, "node": false, "rack": false,
+		"tenant": false, "collector": false} {
+		if err := exporter.checkLabelNames(strings.Split(labels, ",")); (err == nil) != valid {
+			t.Errorf("Checking labels %s had unexpected result. wanted valid %v and got error %v", labels, valid, err)
+		}
+	}
+}
+
+// Verify the namespace is replaced and the relabeling rules are applied to the gathered series
+func TestRelabelingGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	connections := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "maxctrl_server_connections"}, []string{"server", "address"})
+	readEvents := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "maxctrl_status_read_events"}, []string{"id"})
+	uptime := prometheus.NewGauge(prometheus.GaugeOpts{Name: "maxctrl_status_uptime"})
+	registry.MustRegister(connections, readEvents, uptime)
+	connections.WithLabelValues("server1", "10.0.0.1").Set(3)
+	connections.WithLabelValues("server2", "10.0.0.2").Set(1)
+	readEvents.WithLabelValues("0").Add(5)
+
+	configs := []RelabelConfig{
+		{SourceLabels: []string{"__name__", "id"}, Regex: "maxscale_status_.*;.+", Action: "drop"},
+		{SourceLabels: []string{"server"}, Regex: "server2", Action: "drop"},
+		{SourceLabels: []string{"address"}, Regex: `(.*)\.(.*)`, TargetLabel: "host", Replacement: new(string)},
+		{SourceLabels: []string{"address"}, Regex: `10\.(.*)`, TargetLabel: "network"},
+		{Regex: "address", Action: "labeldrop"},
+	}
+	for i := range configs {
+		if err := configs[i].compile(); err != nil {
+			t.Fatalf("Could not compile relabel config: %v", err)
+		}
+	}
+
+	families, err := relabelingGatherer{gatherer: registry, namespace: "maxscale", configs: configs}.Gather()
+	if err != nil {
+		t.Fatalf("Could not gather metrics: %v", err)
+	}
+	if len(families) != 2 || families[0].GetName() != "maxscale_server_connections" || len(families[0].Metric) != 1 ||
+		families[1].GetName() != "maxscale_status_uptime" {
+		t.Fatalf("Gathered unexpected metrics: %v", families)
+	}
+
+	var labels []string
+	for _, pair := range families[0].Metric[0].Label {
+		labels = append(labels, pair.GetName()+"="+pair.GetValue())
+	}
+	if got := strings.Join(labels, ","); got != "network=0.0.1,server=server1" {
+		t.Fatalf("Relabeled series had unexpected labels. wanted 'network=0.0.1,server=server1' and got '%v'", got)
+	}
+}
+
+// Verify the node name is added to the exporter metrics once it is known
+func TestNodeNameLabel(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	sessions := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "maxctrl_status_sessions"}, []string{"id"})
+	goroutines := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines"})
+	registry.MustRegister(sessions, goroutines)
+	sessions.WithLabelValues("0").Set(2)
+
+	nodeName := ""
+	gatherer := relabelingGatherer{gatherer: registry, namespace: Namespace, nodeNameLabel: "node",
+		nodeName: func() string { return nodeName }}
+
+	for _, nodeName = range []string{"", "maxscale-1"} {
+		families, err := gatherer.Gather()
+		if err != nil {
+			t.Fatalf("Could not gather metrics: %v", err)
+		}
+		for _, family := range families {
+			var labels []string
+			for _, pair := range family.Metric[0].Label {
+				labels = append(labels, pair.GetName()+"="+pair.GetValue())
+			}
+			want := ""
+			if family.GetName() == "maxctrl_status_sessions" {
+				want = "id=0"
+				if nodeName != "" {
+					want = "id=0,node=" + nodeName
+				}
+			}
+			if got := strings.Join(labels, ","); got != want {
+				t.Fatalf("Series of %s had unexpected labels. wanted '%s' and got '%s'", family.GetName(), want, got)
+			}
+		}
+	}
+}
+
+// Verify collectors exceeding their series limit keep series of every metric in the order of the label values
+func TestSeriesLimit(t *testing.T) {
+	exporter, err := NewExporter("http://127.0.0.1:8989", "", "", "", false,
+		ExporterOptions{SeriesLimits: map[string]int{"default": 4}})
+	if err != nil {
+		t.Fatalf("Could not create exporter: %v", err)
+	}
+
+	parse := func(m *MaxScale, ch chan<- prometheus.Metric) error {
+		for _, id := range []string{"2", "0", "10", "1"} {
+			m.createMetricForPrometheus(m.statusMetrics, "status_sessions", 1, ch, id)
+		}
+		m.createMetricForPrometheus(m.statusMetrics, "status_read_events", 1, ch, "0")
+		return nil
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	if err := exporter.collect("threads", parse, ch); err != nil {
+		t.Fatalf("Could not collect: %v", err)
+	}
+	close(ch)
+
+	var ids []string
+	for metric := range ch {
+		var series dto.Metric
+		_ = metric.Write(&series)
+		ids = append(ids, series.Label[0].GetValue())
+	}
+	sort.Strings(ids)
+	if got := strings.Join(ids, ","); got != "0,0,1,2" {
+		t.Fatalf("Collected unexpected series. wanted '0,0,1,2' and got '%v'", got)
+	}
+
+	var dropped dto.Metric
+	_ = exporter.seriesDropped.WithLabelValues("threads").Write(&dropped)
+	if dropped.Counter.GetValue() != 1 {
+		t.Fatalf("Dropped series had unexpected value. wanted '1' and got '%v'", dropped.Counter.GetValue())
+	}
+}
+
+// Verify collectors with a refresh interval export their cached series between refreshes
+func TestRefreshInterval(t *testing.T) {
+	setConfigFromEnvironmentVars()
+	parseConfigFile([]byte("refresh_intervals:\n  default: 2min\n  servers: 0\n"))
+	if interval := exporterOptions.refreshInterval("modules"); interval != 2*time.Minute {
+		t.Fatalf("Refresh interval had unexpected value. wanted '2m0s' and got '%v'", interval)
+	}
+
+	exporter, err := NewExporter("http://127.0.0.1:8989", "", "", "", false, exporterOptions)
+	if err != nil {
+		t.Fatalf("Could not create exporter: %v", err)
+	}
+
+	refreshes := 0
+	parse := func(m *MaxScale, ch chan<- prometheus.Metric) error {
+		refreshes++
+		m.createMetricForPrometheus(m.statusMetrics, "status_sessions", refreshes, ch, "0")
+		return nil
+	}
+
+	for _, collector := range []string{"modules", "modules", "servers", "servers"} {
+		ch := make(chan prometheus.Metric, 10)
+		if err := exporter.collect(collector, parse, ch); err != nil {
+			t.Fatalf("Could not collect: %v", err)
+		}
+		if len(ch) != 1 {
+			t.Fatalf("Collected unexpected amount of series. wanted '1' and got '%v'", len(ch))
+		}
+	}
+	if refreshes != 3 {
+		t.Fatalf("Collectors were refreshed unexpectedly often. wanted '3' and got '%v'", refreshes)
+	}
+
+	// Collectors without a successful refresh have no cache age
+	ages := make(chan prometheus.Metric, 10)
+	exporter.cacheAge.Collect(ages)
+	close(ages)
+	for metric := range ages {
+		var age dto.Metric
+		_ = metric.Write(&age)
+		if collector := age.Label[0].GetValue(); collector != "modules" {
+			t.Fatalf("Cache age was exported for collector %s without a refresh", collector)
+		}
+	}
+
+	// A failed refresh exports the cached series and their growing age
+	exporter.caches["modules"] = collectorCache{series: exporter.caches["modules"].series, refreshedAt: time.Now().Add(-3 * time.Minute)}
+	failing := func(m *MaxScale, ch chan<- prometheus.Metric) error {
+		m.createMetricForPrometheus(m.statusMetrics, "status_sessions", 5, ch, "1")
+		return errors.New("MaxScale is down")
+	}
+	ch := make(chan prometheus.Metric, 10)
+	if err := exporter.collect("modules", failing, ch); err == nil {
+		t.Fatalf("Failed refresh did not return an error")
+	}
+	close(ch)
+	var series dto.Metric
+	if len(ch) != 1 || (<-ch).Write(&series) != nil || series.Gauge.GetValue() != 1 {
+		t.Fatalf("Failed refresh did not export the cached series: %v", series.String())
+	}
+	var age dto.Metric
+	_ = exporter.cacheAge.WithLabelValues("modules").Write(&age)
+	if age.Gauge.GetValue() < 180 {
+		t.Fatalf("Cache age had unexpected value. wanted at least '180' and got '%v'", age.Gauge.GetValue())
+	}
+}
+
+var fqNameRegexp = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// collectSeries runs a parse function and returns the exported series as name{labels} and value
+func collectSeries(t *testing.T, parse func(ch chan<- prometheus.Metric) error) map[string]float64 {
+	ch := make(chan prometheus.Metric, 1000)
+	if err := parse(ch); err != nil {
+		t.Fatalf("Could not parse: %v", err)
+	}
+	close(ch)
+
+	series := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("Could not write metric: %v", err)
+		}
+		var labels []string
+		for _, label := range m.Label {
+			labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+		}
+		name := fqNameRegexp.FindStringSubmatch(metric.Desc().String())[1] + "{" + strings.Join(labels, ",") + "}"
+		series[name] = m.GetGauge().GetValue() + m.GetCounter().GetValue() + m.GetUntyped().GetValue()
+	}
+	return series
+}
+
+// checkSeries fails the test unless all wanted series were exported with their value
+func checkSeries(t *testing.T, got map[string]float64, want map[string]float64) {
+	for name, value := range want {
+		gotValue, ok := got[name]
+		if !ok {
+			t.Fatalf("Series %s was not exported, got %v", name, got)
+		}
+		if gotValue != value {
+			t.Fatalf("Series %s had unexpected value. wanted '%v' and got '%v'", name, value, gotValue)
+		}
+	}
+}
+
+// newTestExporter creates an exporter for a MaxScale that is never requested
+func newTestExporter(t *testing.T, options ExporterOptions) *MaxScale {
+	exporter, err := NewExporter("http://127.0.0.1:0", "", "", "", false, options)
+	if err != nil {
+		t.Fatalf("Could not create exporter: %v", err)
+	}
+	return exporter
+}
+
+// Verify the router diagnostics of the supported routers are exported
+func TestRouterDiagnostics(t *testing.T) {
+	tests := []struct {
+		router      string
+		diagnostics string
+		want        map[string]float64
+	}{
+		{"readwritesplit", `{"queries": 120, "route_master": 20, "route_slave": 90, "route_all": 10, "rw_transactions": 5,
+			"ro_transactions": 3, "replayed_transactions": 1, "server_query_statistics": [{"id": "server1", "total": 30,
+			"read": 10, "write": 20, "avg_sess_duration": "1.5s", "avg_selects_per_session": 2.5}]}`, map[string]float64{
+			`maxctrl_rwsplit_queries_total{service="RW"}`:                                        120,
+			`maxctrl_rwsplit_server_writes_total{server="server1",service="RW"}`:                 20,
+			`maxctrl_rwsplit_server_avg_session_duration_seconds{server="server1",service="RW"}`: 1.5,
+		}},
+		{"readconnroute", `{"connections": 14, "current_connections": 2, "queries": 310}`, map[string]float64{
+			`maxctrl_readconnroute_connections_total{service="RW"}`:   14,
+			`maxctrl_readconnroute_current_connections{service="RW"}`: 2,
+			`maxctrl_readconnroute_queries_total{service="RW"}`:       310,
+		}},
+		{"schemarouter", `{"queries": 50, "sescmd_percentage": 12.5, "longest_sescmd_chain": 4, "times_sescmd_limit_exceeded": 0,
+			"longest_session": 30.5, "shortest_session": 0.1, "average_session": 5, "shard_map_hits": 40, "shard_map_misses": 2}`, map[string]float64{
+			`maxctrl_schemarouter_queries_total{service="RW"}`:          50,
+			`maxctrl_schemarouter_sescmd_percentage{service="RW"}`:      12.5,
+			`maxctrl_schemarouter_shard_map_misses_total{service="RW"}`: 2,
+		}},
+		{"binlogrouter", `{"gtid_io_pos": "0-3000-8,1-3000-42", "current_binlog": "binlog.000003", "master_state": "Slave running"}`, map[string]float64{
+			`maxctrl_binlogrouter_info{current_binlog="binlog.000003",master_state="Slave running",service="RW"}`: 1,
+			`maxctrl_binlogrouter_gtid_sequence{domain="0",service="RW"}`:                                         8,
+			`maxctrl_binlogrouter_gtid_sequence{domain="1",service="RW"}`:                                         42,
+		}},
+		{"kafkacdc", `{"events_sent": 1000, "errors": 3}`, map[string]float64{
+			`maxctrl_kafkacdc_events_sent_total{service="RW"}`: 1000,
+			`maxctrl_kafkacdc_errors_total{service="RW"}`:      3,
+		}},
+	}
+
+	exporter := newTestExporter(t, ExporterOptions{})
+	for _, test := range tests {
+		series := collectSeries(t, func(ch chan<- prometheus.Metric) error {
+			return routerDiagnosticsParsers[test.router](exporter, "RW", json.RawMessage(test.diagnostics), ch)
+		})
+		checkSeries(t, series, test.want)
+	}
+}
+
+// Verify the per server diagnostics of the MariaDB monitor are exported
+func TestMariaDBMonitorDiagnostics(t *testing.T) {
+	var diagnostics MonitorDiagnostics
+	err := json.Unmarshal([]byte(`{"primary": true, "master": "server1", "server_info": [
+		{"name": "server1", "read_only": false, "gtid_current_pos": "0-3000-8", "gtid_binlog_pos": "0-3000-8",
+			"lock_held": true, "master_group": null, "slave_connections": []},
+		{"name": "server2", "read_only": true, "gtid_current_pos": "0-3000-7,1-3001-2", "gtid_binlog_pos": "",
+			"lock_held": null, "master_group": 1, "slave_connections": [{"connection_name": "", "master_host": "10.0.0.1",
+			"master_port": 3306, "slave_io_running": "Yes", "slave_sql_running": "No", "seconds_behind_master": null,
+			"last_io_error": "", "last_sql_error": "Duplicate entry"}]}]}`), &diagnostics)
+	if err != nil {
+		t.Fatalf("Could not decode monitor diagnostics: %v", err)
+	}
+
+	exporter := newTestExporter(t, ExporterOptions{})
+	series := collectSeries(t, func(ch chan<- prometheus.Metric) error {
+		exporter.parseMariaDBMonitorDiagnostics("MariaDB-Monitor", diagnostics, ch)
+		return nil
+	})
+
+	checkSeries(t, series, map[string]float64{
+		`maxctrl_mariadbmon_server_read_only{monitor="MariaDB-Monitor",server="server2"}`:                                          1,
+		`maxctrl_mariadbmon_server_gtid_current_sequence{domain="1",monitor="MariaDB-Monitor",server="server2"}`:                   2,
+		`maxctrl_mariadbmon_server_gtid_binlog_sequence{domain="0",monitor="MariaDB-Monitor",server="server1"}`:                    8,
+		`maxctrl_mariadbmon_server_lock_held{monitor="MariaDB-Monitor",server="server1"}`:                                          1,
+		`maxctrl_mariadbmon_server_master_group{monitor="MariaDB-Monitor",server="server2"}`:                                       1,
+		`maxctrl_mariadbmon_slave_io_running{connection="",master="10.0.0.1:3306",monitor="MariaDB-Monitor",server="server2"}`:     1,
+		`maxctrl_mariadbmon_slave_sql_running{connection="",master="10.0.0.1:3306",monitor="MariaDB-Monitor",server="server2"}`:    0,
+		`maxctrl_mariadbmon_slave_last_sql_error{connection="",master="10.0.0.1:3306",monitor="MariaDB-Monitor",server="server2"}`: 1,
+	})
+
+	// null values are not exported
+	for _, name := range []string{
+		`maxctrl_mariadbmon_server_lock_held{monitor="MariaDB-Monitor",server="server2"}`,
+		`maxctrl_mariadbmon_server_master_group{monitor="MariaDB-Monitor",server="server1"}`,
+		`maxctrl_mariadbmon_slave_seconds_behind_master{connection="",master="10.0.0.1:3306",monitor="MariaDB-Monitor",server="server2"}`,
+	} {
+		if _, ok := series[name]; ok {
+			t.Fatalf("Series %s was unexpectedly exported", name)
+		}
+	}
+}
+
+// Verify the Galera monitor diagnostics are exported and nodes outside the majority
+// cluster are flagged, ties going to the lower cluster UUID
+func TestGaleraMonitorDiagnostics(t *testing.T) {
+	tests := []struct {
+		serverInfo string
+		mismatches map[string]float64
+	}{
+		{`[{"name": "g1", "cluster_uuid": "bbb"}, {"name": "g2", "cluster_uuid": "bbb"}, {"name": "g3", "cluster_uuid": "aaa"}]`,
+			map[string]float64{"g1": 0, "g2": 0, "g3": 1}},
+		{`[{"name": "g1", "cluster_uuid": "bbb"}, {"name": "g2", "cluster_uuid": "aaa"}, {"name": "g3", "cluster_uuid": ""}]`,
+			map[string]float64{"g1": 1, "g2": 0, "g3": 0}},
+	}
+
+	exporter := newTestExporter(t, ExporterOptions{})
+	for _, test := range tests {
+		var diagnostics MonitorDiagnostics
+		err := json.Unmarshal([]byte(`{"use_priority": true, "server_info": `+test.serverInfo+`}`), &diagnostics)
+		if err != nil {
+			t.Fatalf("Could not decode monitor diagnostics: %v", err)
+		}
+
+		series := collectSeries(t, func(ch chan<- prometheus.Metric) error {
+			exporter.parseGaleraMonitorDiagnostics("Galera-Monitor", diagnostics, ch)
+			return nil
+		})
+
+		want := map[string]float64{`maxctrl_galeramon_use_priority{monitor="Galera-Monitor"}`: 1}
+		for server, mismatch := range test.mismatches {
+			want[`maxctrl_galeramon_server_cluster_uuid_mismatch{monitor="Galera-Monitor",server="`+server+`"}`] = mismatch
+		}
+		checkSeries(t, series, want)
+	}
+
+	var diagnostics MonitorDiagnostics
+	err := json.Unmarshal([]byte(`{"server_info": [{"name": "g1", "joined": true, "local_index": 1, "local_state": 4,
+		"cluster_uuid": "aaa", "cluster_size": 3}]}`), &diagnostics)
+	if err != nil {
+		t.Fatalf("Could not decode monitor diagnostics: %v", err)
+	}
+	series := collectSeries(t, func(ch chan<- prometheus.Metric) error {
+		exporter.parseGaleraMonitorDiagnostics("Galera-Monitor", diagnostics, ch)
+		return nil
+	})
+	checkSeries(t, series, map[string]float64{
+		`maxctrl_galeramon_server_joined{monitor="Galera-Monitor",server="g1"}`:                          1,
+		`maxctrl_galeramon_server_local_index{monitor="Galera-Monitor",server="g1"}`:                     1,
+		`maxctrl_galeramon_server_local_state{monitor="Galera-Monitor",server="g1"}`:                     4,
+		`maxctrl_galeramon_server_cluster_size{monitor="Galera-Monitor",server="g1"}`:                    3,
+		`maxctrl_galeramon_server_cluster_info{cluster_uuid="aaa",monitor="Galera-Monitor",server="g1"}`: 1,
+	})
+}
+
+// Verify the filter diagnostics of the supported filters are exported
+func TestFilterDiagnostics(t *testing.T) {
+	tests := []struct {
+		module      string
+		diagnostics string
+		want        map[string]float64
+	}{
+		{"cache", `{"hits": 90, "misses": 10, "updates": 8, "deletes": 1, "evictions": 2, "invalidations": 0, "size": 4096, "items": 7}`, map[string]float64{
+			`maxctrl_cache_hits_total{filter="F"}`:   90,
+			`maxctrl_cache_misses_total{filter="F"}`: 10,
+			`maxctrl_cache_size_bytes{filter="F"}`:   4096,
+			`maxctrl_cache_items{filter="F"}`:        7,
+		}},
+		{"throttlefilter", `{"throttled_sessions": 4}`, map[string]float64{
+			`maxctrl_throttle_throttled_sessions_total{filter="F"}`: 4,
+		}},
+		{"tee", `{"branch_failures": 2}`, map[string]float64{
+			`maxctrl_tee_branch_failures_total{filter="F"}`: 2,
+		}},
+		{"namedserverfilter", `{"total_diverted": 30, "total_undiverted": 70}`, map[string]float64{
+			`maxctrl_namedserverfilter_diverted_total{filter="F"}`:   30,
+			`maxctrl_namedserverfilter_undiverted_total{filter="F"}`: 70,
+		}},
+	}
+
+	exporter := newTestExporter(t, ExporterOptions{})
+	for _, test := range tests {
+		series := collectSeries(t, func(ch chan<- prometheus.Metric) error {
+			return filterDiagnosticsParsers[test.module](exporter, "F", json.RawMessage(test.diagnostics), ch)
+		})
+		checkSeries(t, series, test.want)
+	}
+}
+
+// newFakeMaxScaleExporter creates an exporter for a fake MaxScale answering the REST API
+// paths below /v1 with the given JSON documents
+func newFakeMaxScaleExporter(t *testing.T, responses map[string]string, options ExporterOptions) *MaxScale {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[strings.TrimPrefix(r.URL.Path, "/v1")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	exporter, err := NewExporter(server.URL, "", "", "", false, options)
+	if err != nil {
+		t.Fatalf("Could not create exporter: %v", err)
+	}
+	return exporter
+}
+
+// Verify the relationships between services, servers, monitors, filters and listeners are exported
+func TestRelationships(t *testing.T) {
+	exporter := newFakeMaxScaleExporter(t, map[string]string{
+		"/services": `{"data": [{"id": "RW", "attributes": {"router": "readwritesplit", "state": "Started"},
+			"relationships": {"servers": {"data": [{"id": "server1", "type": "servers"}, {"id": "server2", "type": "servers"}]},
+			"filters": {"data": [{"id": "Cache", "type": "filters"}, {"id": "Tee", "type": "filters"}]}}}]}`,
+		"/monitors": `{"data": [{"id": "MariaDB-Monitor", "attributes": {"module": "galeramon", "state": "Running"},
+			"relationships": {"servers": {"data": [{"id": "server1", "type": "servers"}]}}}]}`,
+		"/listeners": `{"data": [{"id": "RW-Listener", "attributes": {"state": "Running", "parameters": {"port": 4006}},
+			"relationships": {"services": {"data": [{"id": "RW", "type": "services"}]}}}]}`,
+	}, ExporterOptions{})
+
+	series := collectSeries(t, func(ch chan<- prometheus.Metric) error {
+		for _, parse := range []func(chan<- prometheus.Metric) error{exporter.parseServices, exporter.parseMonitors, exporter.parseListeners} {
+			if err := parse(ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	checkSeries(t, series, map[string]float64{
+		`maxctrl_service_server{server="server1",service="RW"}`:              1,
+		`maxctrl_service_server{server="server2",service="RW"}`:              1,
+		`maxctrl_service_filter{filter="Cache",position="1",service="RW"}`:   1,
+		`maxctrl_service_filter{filter="Tee",position="2",service="RW"}`:     1,
+		`maxctrl_monitor_server{monitor="MariaDB-Monitor",server="server1"}`: 1,
+		`maxctrl_listener_service{listener="RW-Listener",service="RW"}`:      1,
+	})
+}
+
+// Verify the listeners of the services are exported when MaxScale has no /listeners
+func TestServiceListeners(t *testing.T) {
+	exporter := newFakeMaxScaleExporter(t, map[string]string{
+		"/services": `{"data": [{"id": "RW", "attributes": {"router": "readwritesplit", "state": "Started",
+			"listeners": [{"id": "RW-Listener", "type": "listeners", "attributes": {"state": "Running",
+			"parameters": {"address": "::", "port": 4006, "protocol": "MariaDBClient", "authenticator": "MySQLAuth", "ssl": "disabled"}}}]}}]}`,
+	}, ExporterOptions{})
+
+	series := collectSeries(t, exporter.parseListeners)
+
+	checkSeries(t, series, map[string]float64{
+		`maxctrl_listener_service{listener="RW-Listener",service="RW"}`:                                                                                      1,
+		`maxctrl_listener_info{address="::",authenticator="MySQLAuth",listener="RW-Listener",port="4006",protocol="MariaDBClient",service="RW",ssl="false"}`: 1,
+	})
+}
+
+// Verify every REST API path is requested once per scrape, even if several collectors read it
+func TestSharedResponses(t *testing.T) {
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/v1/servers", "/v1/services", "/v1/monitors", "/v1/filters", "/v1/listeners":
+			_, _ = w.Write([]byte(`{"data": []}`))
+		case "/v1/maxscale":
+			_, _ = w.Write([]byte(`{"data": {"attributes": {"parameters": {"threads": 4}, "uptime": 10}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL, "", "", "", false, ExporterOptions{Parameters: ParametersOptions{Enabled: true}})
+	if err != nil {
+		t.Fatalf("Could not create exporter: %v", err)
+	}
+
+	for scrape := 1; scrape <= 2; scrape++ {
+		ch := make(chan prometheus.Metric, 1000)
+		exporter.Collect(ch)
+		close(ch)
+		for path, count := range requests {
+			if count != scrape {
+				t.Fatalf("Path %s was requested %d times in %d scrapes", path, count, scrape)
+			}
+		}
+	}
+	if requests["/v1/maxscale"] == 0 {
+		t.Fatalf("Path /v1/maxscale was never requested")
+	}
+}
+
+// Verify the thread session counts and listening flag are only exported when MaxScale reports them
+func TestThreadStatus(t *testing.T) {
+	exporter := newFakeMaxScaleExporter(t, map[string]string{
+		"/maxscale/threads": `{"data": [{"id": "0", "attributes": {"stats": {"reads": 5, "sessions": 3, "zombies": 0, "listening": true}}},
+			{"id": "1", "attributes": {"stats": {"reads": 7}}}]}`,
+	}, ExporterOptions{})
+
+	series := collectSeries(t, exporter.parseThreadStatus)
+
+	checkSeries(t, series, map[string]float64{
+		`maxctrl_status_read_events{id="0"}`: 5,
+		`maxctrl_status_read_events{id="1"}`: 7,
+		`maxctrl_status_sessions{id="0"}`:    3,
+		`maxctrl_status_zombies{id="0"}`:     0,
+		`maxctrl_status_listening{id="0"}`:   1,
+	})
+	for _, name := range []string{`maxctrl_status_sessions{id="1"}`, `maxctrl_status_zombies{id="1"}`, `maxctrl_status_listening{id="1"}`} {
+		if _, ok := series[name]; ok {
+			t.Errorf("Series %s was exported although MaxScale did not report it", name)
+		}
+	}
+}
+
+// Verify monitor events are counted once when they change between scrapes, but not the
+// event already present on the first scrape
+func TestServerEvents(t *testing.T) {
+	responses := map[string]string{}
+	exporter := newFakeMaxScaleExporter(t, responses, ExporterOptions{})
+	serverEvent := func(event string, triggeredAt string) string {
+		return `{"data": [{"id": "server1", "attributes": {"state": "Running", "parameters": {"address": "10.0.0.1"},
+			"last_event": "` + event + `", "triggered_at": "` + triggeredAt + `"}}]}`
+	}
+
+	scrapes := []struct {
+		servers string
+		want    map[string]float64
+	}{
+		{serverEvent("master_down", "Mon, 01 Jan 2024 10:00:00 GMT"), map[string]float64{
+			`maxctrl_server_events_total{event="master_down"}`:                                  0,
+			`maxctrl_server_last_event_timestamp_seconds{event="master_down",server="server1"}`: 1704103200,
+		}},
+		{serverEvent("master_up", "Mon, 01 Jan 2024 10:05:00 GMT"), map[string]float64{
+			`maxctrl_server_events_total{event="master_down"}`:                                0,
+			`maxctrl_server_events_total{event="master_up"}`:                                  1,
+			`maxctrl_server_last_event_timestamp_seconds{event="master_up",server="server1"}`: 1704103500,
+		}},
+		{serverEvent("master_up", "Mon, 01 Jan 2024 10:05:00 GMT"), map[string]float64{
+			`maxctrl_server_events_total{event="master_down"}`: 0,
+			`maxctrl_server_events_total{event="master_up"}`:   1,
+		}},
+	}
+
+	for _, scrape := range scrapes {
+		responses["/servers"] = scrape.servers
+		checkSeries(t, collectSeries(t, exporter.parseServers), scrape.want)
+	}
+}
+
+// Verify sessions exceeding the thresholds are counted per service and rule while the
+// session counts stay disabled
+func TestStuckSessions(t *testing.T) {
+	now := time.Now().UTC().Format(http.TimeFormat)
+	connected := "Mon, 01 Jan 2024 10:00:00 GMT"
+	session := func(id string, service string, connected string, idle int, queries string) string {
+		return fmt.Sprintf(`{"id": "%s", "attributes": {"connected": "%s", "idle": %d, "queries": [%s]},
+			"relationships": {"services": {"data": [{"id": "%s"}]}}}`, id, connected, idle, queries, service)
+	}
+	exporter := newFakeMaxScaleExporter(t, map[string]string{
+		"/sessions": `{"data": [` + strings.Join([]string{
+			// idle in an open transaction
+			session("1", "RW", now, 120, `{"statement": "BEGIN", "completed": "x"}, {"statement": "UPDATE t SET a = 1", "completed": "x"}`),
+			// waiting for a query inside a transaction is not idle, but old with a pending query
+			session("2", "RW", connected, 120, `{"statement": "BEGIN", "completed": "x"}, {"statement": "SELECT SLEEP(600)"}`),
+			session("3", "RO", now, 0, ``),
+		}, ",") + `]}`,
+	}, ExporterOptions{Sessions: SessionsOptions{
+		Thresholds: SessionThresholds{IdleInTransaction: 60, Age: 3600, PendingQueries: 1},
+	}})
+
+	connectedAt, _ := http.ParseTime(connected)
+	minAge := time.Since(connectedAt).Seconds()
+	series := collectSeries(t, exporter.parseSessions)
+	maxAge := time.Since(connectedAt).Seconds()
+
+	checkSeries(t, series, map[string]float64{
+		`maxctrl_sessions_over_threshold{rule="idle_in_transaction",service="RW"}`: 1,
+		`maxctrl_sessions_over_threshold{rule="age",service="RW"}`:                 1,
+		`maxctrl_sessions_over_threshold{rule="pending_queries",service="RW"}`:     1,
+		`maxctrl_sessions_over_threshold{rule="idle_in_transaction",service="RO"}`: 0,
+		`maxctrl_sessions_over_threshold{rule="age",service="RO"}`:                 0,
+		`maxctrl_sessions_over_threshold{rule="pending_queries",service="RO"}`:     0,
+		`maxctrl_sessions_oldest_offending_age_seconds{service="RO"}`:              0,
+	})
+	if age := series[`maxctrl_sessions_oldest_offending_age_seconds{service="RW"}`]; age < minAge || age > maxAge {
+		t.Fatalf("Oldest offending session age had unexpected value. wanted between '%v' and '%v' and got '%v'", minAge, maxAge, age)
+	}
+	for name := range series {
+		if strings.HasPrefix(name, "maxctrl_sessions{") || strings.HasPrefix(name, "maxctrl_session_") {
+			t.Fatalf("Series %s was exported although the sessions collector is disabled", name)
+		}
+	}
+}
+
+// Verify a MaxScale restart is detected from its uptime going backwards
+func TestMaxscaleRestarts(t *testing.T) {
+	responses := map[string]string{}
+	exporter := newFakeMaxScaleExporter(t, responses, ExporterOptions{})
+
+	for _, scrape := range []struct {
+		uptime   int
+		restarts float64
+	}{{3600, 0}, {3660, 0}, {30, 1}, {90, 1}} {
+		responses["/maxscale"] = fmt.Sprintf(`{"data": {"attributes": {"uptime": %d, "version": "6.4.0"}}}`, scrape.uptime)
+		checkSeries(t, collectSeries(t, exporter.parseMaxscaleStatus), map[string]float64{
+			`maxctrl_status_uptime{}`:           float64(scrape.uptime),
+			`maxctrl_maxscale_restarts_total{}`: scrape.restarts,
+		})
+	}
+}
+
+// Verify long statements are shortened to unique label values
+func TestStatementLabel(t *testing.T) {
+	if got := statementLabel("SELECT ?"); got != "SELECT ?" {
+		t.Fatalf("Short statement was changed to '%s'", got)
+	}
+
+	prefix := "SELECT " + strings.Repeat("ä", 150) + " FROM t WHERE "
+	first, second := statementLabel(prefix+"a = ?"), statementLabel(prefix+"b = ?")
+	for _, label := range []string{first, second} {
+		if len(label) > maxStatementLabelLength || !utf8.ValidString(label) || !strings.HasPrefix(label, "SELECT ää") {
+			t.Fatalf("Long statement was shortened to unexpected label '%s'", label)
+		}
+	}
+	if first == second {
+		t.Fatalf("Different statements were shortened to the same label '%s'", first)
+	}
+}
@@ -13,6 +13,16 @@
 
 package main
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Servers structure reflects JSON object returned by MaxScale REST API
 // <maxscale url>/v1/servers
 type Servers struct {
@@ -21,16 +31,21 @@ type Servers struct {
 	Data []struct {
 		ID string `json:"id"`
 		//nolint
-		Type string `json:"type"`
-		//nolint
-		Relationships interface {
+		Type          string `json:"type"`
+		Relationships struct {
+			Monitors Relationship `json:"monitors"`
 		} `json:"relationships"`
 		Attributes struct {
 			Parameters struct {
-				Address string `json:"address"`
+				Address  string `json:"address"`
+				Port     int    `json:"port"`
+				Rank     string `json:"rank"`
+				Priority int    `json:"priority"`
 				// add other parameters if needed
 			} `json:"parameters"`
-			State string `json:"state"`
+			State       string `json:"state"`
+			LastEvent   string `json:"last_event"`
+			TriggeredAt string `json:"triggered_at"`
 			// add other parameters if needed
 			Statistics struct {
 				Connections int `json:"connections"`
@@ -52,22 +67,32 @@ type Services struct {
 		ID string `json:"id"`
 		// add other parameters if needed
 		Attributes struct {
-			Router      string `json:"router"`
-			Connections int    `json:"connections"`
-			//nolint
-			RouterDiagnostics interface {
-			} `json:"router_diagnostics"`
+			Router           string `json:"router"`
+			State            string `json:"state"`
+			Started          string `json:"started"`
+			Connections      int    `json:"connections"`
+			TotalConnections int    `json:"total_connections"`
+			Statistics       struct {
+				Connections      int `json:"connections"`
+				TotalConnections int `json:"total_connections"`
+				MaxConnections   int `json:"max_connections"`
+				RoutedPackets    int `json:"routed_packets"`
+				ActiveOperations int `json:"active_operations"`
+				FailedAuths      int `json:"failed_auths"`
+			} `json:"statistics"`
+			// decoded according to the router, see parseServices
+			RouterDiagnostics json.RawMessage `json:"router_diagnostics"`
 			//nolint
 			Parameters struct {
 				MaxConnections int `json:"max_connections"`
 			} `json:"parameters"`
-			//nolint
-			Listeners []interface {
-			} `json:"listeners"`
+			// the listeners of the service, see serviceListeners
+			Listeners json.RawMessage `json:"listeners"`
 			// add other parameters if needed
 		} `json:"attributes"`
-		//nolint
-		Relationships interface {
+		Relationships struct {
+			Servers Relationship `json:"servers"`
+			Filters Relationship `json:"filters"`
 		} `json:"relationships"`
 		//nolint
 		Links interface {
@@ -84,22 +109,23 @@ type Monitors struct {
 		ID string `json:"id"`
 		// add other parameters if needed
 		Attributes struct {
-			Module string `json:"module"`
-			//nolint
-			MonitorDiagnostics struct {
-				Primary bool   `json:"primary"`
-				Master  string `json:"master"`
-			} `json:"monitor_diagnostics"`
-			//nolint
-			Parameters struct {
-				CooperativeMonitoringLocks string `json:"cooperative_monitoring_locks"`
-				AutoFailover               bool   `json:"auto_failover"`
-				AutoRejoin                 bool   `json:"auto_rejoin"`
+			Module             string             `json:"module"`
+			State              string             `json:"state"`
+			Ticks              int                `json:"ticks"`
+			MonitorDiagnostics MonitorDiagnostics `json:"monitor_diagnostics"`
+			Parameters         struct {
+				MonitorInterval            MillisecondDuration `json:"monitor_interval"`
+				CooperativeMonitoringLocks string              `json:"cooperative_monitoring_locks"`
+				AutoFailover               bool                `json:"auto_failover"`
+				AutoRejoin                 bool                `json:"auto_rejoin"`
+				Failcount                  int                 `json:"failcount"`
+				SwitchoverTimeout          Duration            `json:"switchover_timeout"`
+				FailoverTimeout            Duration            `json:"failover_timeout"`
+				EnforceReadOnlySlaves      bool                `json:"enforce_read_only_slaves"`
 			} `json:"parameters"`
-			//nolint
 		} `json:"attributes"`
-		//nolint
-		Relationships interface {
+		Relationships struct {
+			Servers Relationship `json:"servers"`
 		} `json:"relationships"`
 		//nolint
 		Links interface {
@@ -107,6 +133,115 @@ type Monitors struct {
 	} `json:"data"`
 }
 
+// MonitorDiagnostics structure reflects the monitor_diagnostics object returned by
+// MaxScale REST API <maxscale url>/v1/monitors. The fields depend on the monitor module,
+// fields not reported by a module keep their zero values.
+type MonitorDiagnostics struct {
+	// mariadbmon
+	Primary bool   `json:"primary"`
+	Master  string `json:"master"`
+	// galeramon
+	DisableMasterFailback    bool `json:"disable_master_failback"`
+	DisableMasterRoleSetting bool `json:"disable_master_role_setting"`
+	RootNodeAsMaster         bool `json:"root_node_as_master"`
+	UsePriority              bool `json:"use_priority"`
+	ServerInfo               []struct {
+		Name           string `json:"name"`
+		ReadOnly       bool   `json:"read_only"`
+		GTIDCurrentPos string `json:"gtid_current_pos"`
+		GTIDBinlogPos  string `json:"gtid_binlog_pos"`
+		// mariadbmon
+		LockHeld         *bool `json:"lock_held"`
+		MasterGroup      *int  `json:"master_group"`
+		SlaveConnections []struct {
+			ConnectionName      string `json:"connection_name"`
+			MasterHost          string `json:"master_host"`
+			MasterPort          int    `json:"master_port"`
+			SlaveIORunning      string `json:"slave_io_running"`
+			SlaveSQLRunning     string `json:"slave_sql_running"`
+			SecondsBehindMaster *int   `json:"seconds_behind_master"`
+			LastIOError         string `json:"last_io_error"`
+			LastSQLError        string `json:"last_sql_error"`
+		} `json:"slave_connections"`
+		// galeramon
+		Joined      bool   `json:"joined"`
+		LocalIndex  int    `json:"local_index"`
+		LocalState  int    `json:"local_state"`
+		ClusterUUID string `json:"cluster_uuid"`
+		ClusterSize int    `json:"cluster_size"`
+	} `json:"server_info"`
+}
+
+// Listeners structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/listeners
+type Listeners struct {
+	Links interface {
+	} `json:"links"`
+	Data []Listener `json:"data"`
+}
+
+// Listener is a listener in /v1/listeners or, in older MaxScale versions, in the
+// attributes of its service
+type Listener struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		State      string `json:"state"`
+		Parameters struct {
+			Address       string `json:"address"`
+			Port          int    `json:"port"`
+			Protocol      string `json:"protocol"`
+			Authenticator string `json:"authenticator"`
+			// a boolean, older MaxScale versions report "required" or "disabled"
+			SSL json.RawMessage `json:"ssl"`
+			// add other parameters if needed
+		} `json:"parameters"`
+	} `json:"attributes"`
+	Relationships struct {
+		Services Relationship `json:"services"`
+	} `json:"relationships"`
+}
+
+// Filters structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/filters
+type Filters struct {
+	Links interface {
+	} `json:"links"`
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Module string `json:"module"`
+			// decoded according to the module, see parseFilters
+			FilterDiagnostics json.RawMessage `json:"filter_diagnostics"`
+			// add other parameters if needed
+		} `json:"attributes"`
+		Relationships struct {
+			Services Relationship `json:"services"`
+		} `json:"relationships"`
+	} `json:"data"`
+}
+
+// ObjectParameters structure reflects the parameters of the JSON objects returned by
+// MaxScale REST API <maxscale url>/v1/servers, /v1/services, /v1/monitors, /v1/filters
+// and /v1/listeners
+type ObjectParameters struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Parameters map[string]json.RawMessage `json:"parameters"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// MaxscaleParameters structure reflects the parameters of the JSON object returned by
+// MaxScale REST API <maxscale url>/v1/maxscale
+type MaxscaleParameters struct {
+	Data struct {
+		Attributes struct {
+			Parameters map[string]json.RawMessage `json:"parameters"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
 // MaxscaleStatus structure reflects JSON object returned by MaxScale REST API
 // <maxscale url>/v1/maxscale
 type MaxscaleStatus struct {
@@ -122,6 +257,24 @@ type MaxscaleStatus struct {
 				// add other parameters if needed
 			} `json:"parameters"`
 			Uptime int `json:"uptime"`
+
+			Version     string `json:"version"`
+			Commit      string `json:"commit"`
+			StartedAt   string `json:"started_at"`
+			ActivatedAt string `json:"activated_at"`
+			System      struct {
+				OS struct {
+					Nodename string `json:"nodename"`
+				} `json:"os"`
+			} `json:"system"`
+			// null unless config_sync_cluster is configured
+			ConfigSync *struct {
+				Version  int               `json:"version"`
+				Checksum string            `json:"checksum"`
+				Origin   string            `json:"origin"`
+				Status   string            `json:"status"`
+				Nodes    map[string]string `json:"nodes"`
+			} `json:"config_sync"`
 			// add other parameters if needed
 		} `json:"attributes"`
 		// add other parameters if needed
@@ -138,29 +291,35 @@ type ThreadStatus struct {
 		// add other parameters if needed
 		Attributes struct {
 			Stats struct {
-				Reads               int `json:"reads"`
-				Writes              int `json:"writes"`
-				Errors              int `json:"errors"`
-				Hangups             int `json:"hangups"`
-				Accepts             int `json:"accepts"`
-				AvgEventQueueLength int `json:"avg_event_queue_length"`
-				MaxEventQueueLength int `json:"max_event_queue_length"`
-				MaxExecTime         int `json:"max_exec_time"`
-				MaxQueueTime        int `json:"max_queue_time"`
-				CurrentDescriptors  int `json:"current_descriptors"`
-				TotalDescriptors    int `json:"total_descriptors"`
+				Reads               float64 `json:"reads"`
+				Writes              float64 `json:"writes"`
+				Errors              float64 `json:"errors"`
+				Hangups             float64 `json:"hangups"`
+				Accepts             float64 `json:"accepts"`
+				AvgEventQueueLength float64 `json:"avg_event_queue_length"`
+				MaxEventQueueLength float64 `json:"max_event_queue_length"`
+				MaxExecTime         float64 `json:"max_exec_time"`
+				MaxQueueTime        float64 `json:"max_queue_time"`
+				CurrentDescriptors  float64 `json:"current_descriptors"`
+				TotalDescriptors    float64 `json:"total_descriptors"`
 				Load                struct {
-					LastSecond int `json:"last_second"`
-					LastMinute int `json:"last_minute"`
-					LastHour   int `json:"last_hour"`
+					LastSecond float64 `json:"last_second"`
+					LastMinute float64 `json:"last_minute"`
+					LastHour   float64 `json:"last_hour"`
 				} `json:"load"`
 				QueryClassifierCache struct {
-					Size      int `json:"size"`
-					Inserts   int `json:"inserts"`
-					Hits      int `json:"hits"`
-					Misses    int `json:"misses"`
-					Evictions int `json:"evictions"`
+					Size      float64 `json:"size"`
+					Inserts   float64 `json:"inserts"`
+					Hits      float64 `json:"hits"`
+					Misses    float64 `json:"misses"`
+					Evictions float64 `json:"evictions"`
 				} `json:"query_classifier_cache"`
+				// nil when not reported by older MaxScale versions
+				Sessions  *float64    `json:"sessions"`
+				Zombies   *float64    `json:"zombies"`
+				Listening *bool       `json:"listening"`
+				State     string      `json:"state"`
+				Memory    MemoryUsage `json:"memory"`
 			} `json:"stats"`
 		} `json:"attributes"`
 		//nolint
@@ -168,3 +327,452 @@ type ThreadStatus struct {
 		} `json:"links"`
 	} `json:"data"`
 }
+
+// MemoryStatus structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/maxscale/memory
+type MemoryStatus struct {
+	Links interface {
+	} `json:"links"`
+	Data struct {
+		Attributes struct {
+			Memory MemoryUsage `json:"memory"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// MemoryUsage reflects the memory accounting of MaxScale in bytes, both in total
+// and per worker thread
+type MemoryUsage struct {
+	QueryClassifier float64 `json:"query_classifier"`
+	Zombies         float64 `json:"zombies"`
+	Sessions        float64 `json:"sessions"`
+	Total           float64 `json:"total"`
+}
+
+// Sessions structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/sessions
+type Sessions struct {
+	Links interface {
+	} `json:"links"`
+	Data []Session `json:"data"`
+}
+
+// Session reflects a single session of <maxscale url>/v1/sessions
+type Session struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		State     string  `json:"state"`
+		User      string  `json:"user"`
+		Remote    string  `json:"remote"`
+		Connected string  `json:"connected"`
+		Idle      float64 `json:"idle"`
+		// only reported when retain_last_statements is enabled
+		Queries []struct {
+			Statement string `json:"statement"`
+			Received  string `json:"received"`
+			Completed string `json:"completed"`
+		} `json:"queries"`
+		// add other parameters if needed
+	} `json:"attributes"`
+	Relationships struct {
+		Services Relationship `json:"services"`
+	} `json:"relationships"`
+}
+
+// service returns the name of the service the session belongs to
+func (s Session) service() string {
+	if len(s.Relationships.Services.Data) == 0 {
+		return ""
+	}
+	return s.Relationships.Services.Data[0].ID
+}
+
+// age returns the seconds since the session was connected
+func (s Session) age(now time.Time) (float64, bool) {
+	connected, err := http.ParseTime(s.Attributes.Connected)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(connected).Seconds(), true
+}
+
+// pendingQueries returns the number of retained statements that have not completed yet
+func (s Session) pendingQueries() int {
+	pending := 0
+	for _, query := range s.Attributes.Queries {
+		if query.Completed == "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+// inTransaction tells from the retained statements whether the session has an open
+// transaction. Without retained statements a session is never in a transaction.
+func (s Session) inTransaction() bool {
+	open := false
+	for _, query := range s.Attributes.Queries {
+		statement := strings.ToUpper(strings.TrimSpace(query.Statement))
+		switch {
+		case strings.HasPrefix(statement, "BEGIN"),
+			strings.HasPrefix(statement, "START TRANSACTION"),
+			strings.HasPrefix(statement, "XA START"),
+			strings.HasPrefix(statement, "XA BEGIN"):
+			open = true
+		case strings.HasPrefix(statement, "COMMIT"),
+			strings.HasPrefix(statement, "XA COMMIT"),
+			strings.HasPrefix(statement, "XA ROLLBACK"),
+			strings.HasPrefix(statement, "ROLLBACK") && !strings.HasPrefix(statement, "ROLLBACK TO"):
+			open = false
+		}
+	}
+	return open
+}
+
+// QueryClassifier structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/maxscale/query_classifier
+type QueryClassifier struct {
+	Links interface {
+	} `json:"links"`
+	Data struct {
+		Attributes struct {
+			Module     string `json:"module"`
+			Parameters struct {
+				CacheSize int `json:"cache_size"`
+				// add other parameters if needed
+			} `json:"parameters"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// QueryClassifierCache structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/maxscale/query_classifier/cache
+type QueryClassifierCache struct {
+	Links interface {
+	} `json:"links"`
+	Data []struct {
+		// the canonical form of the statement
+		ID         string `json:"id"`
+		Attributes struct {
+			Hits int `json:"hits"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// Modules structure reflects JSON object returned by MaxScale REST API
+// <maxscale url>/v1/maxscale/modules
+type Modules struct {
+	Links interface {
+	} `json:"links"`
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			ModuleType string `json:"module_type"`
+			Version    string `json:"version"`
+			Maturity   string `json:"maturity"`
+			API        string `json:"api"`
+			// add other parameters if needed
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// RWSplitDiagnostics structure reflects the router_diagnostics object of a
+// readwritesplit service returned by MaxScale REST API <maxscale url>/v1/services
+type RWSplitDiagnostics struct {
+	Queries               int `json:"queries"`
+	RouteMaster           int `json:"route_master"`
+	RouteSlave            int `json:"route_slave"`
+	RouteAll              int `json:"route_all"`
+	RWTransactions        int `json:"rw_transactions"`
+	ROTransactions        int `json:"ro_transactions"`
+	ReplayedTransactions  int `json:"replayed_transactions"`
+	ServerQueryStatistics []struct {
+		ID                   string   `json:"id"`
+		Total                int      `json:"total"`
+		Read                 int      `json:"read"`
+		Write                int      `json:"write"`
+		AvgSessDuration      Duration `json:"avg_sess_duration"`
+		AvgSelectsPerSession float64  `json:"avg_selects_per_session"`
+	} `json:"server_query_statistics"`
+}
+
+// ReadConnRouteDiagnostics structure reflects the router_diagnostics object of a
+// readconnroute service returned by MaxScale REST API <maxscale url>/v1/services
+type ReadConnRouteDiagnostics struct {
+	Connections        int `json:"connections"`
+	CurrentConnections int `json:"current_connections"`
+	Queries            int `json:"queries"`
+}
+
+// SchemaRouterDiagnostics structure reflects the router_diagnostics object of a
+// schemarouter service returned by MaxScale REST API <maxscale url>/v1/services
+type SchemaRouterDiagnostics struct {
+	Queries                  int     `json:"queries"`
+	SescmdPercentage         float64 `json:"sescmd_percentage"`
+	LongestSescmdChain       int     `json:"longest_sescmd_chain"`
+	TimesSescmdLimitExceeded int     `json:"times_sescmd_limit_exceeded"`
+	LongestSession           float64 `json:"longest_session"`
+	ShortestSession          float64 `json:"shortest_session"`
+	AverageSession           float64 `json:"average_session"`
+	ShardMapHits             int     `json:"shard_map_hits"`
+	ShardMapMisses           int     `json:"shard_map_misses"`
+}
+
+// BinlogRouterDiagnostics structure reflects the router_diagnostics object of a
+// binlogrouter service returned by MaxScale REST API <maxscale url>/v1/services
+type BinlogRouterDiagnostics struct {
+	GTIDIOPos     string `json:"gtid_io_pos"`
+	CurrentBinlog string `json:"current_binlog"`
+	MasterState   string `json:"master_state"`
+}
+
+// KafkaCDCDiagnostics structure reflects the router_diagnostics object of a
+// kafkacdc service returned by MaxScale REST API <maxscale url>/v1/services
+type KafkaCDCDiagnostics struct {
+	EventsSent int `json:"events_sent"`
+	Errors     int `json:"errors"`
+}
+
+// CacheDiagnostics structure reflects the filter_diagnostics object of a cache
+// filter returned by MaxScale REST API <maxscale url>/v1/filters
+type CacheDiagnostics struct {
+	Hits          int `json:"hits"`
+	Misses        int `json:"misses"`
+	Updates       int `json:"updates"`
+	Deletes       int `json:"deletes"`
+	Evictions     int `json:"evictions"`
+	Invalidations int `json:"invalidations"`
+	Size          int `json:"size"`
+	Items         int `json:"items"`
+}
+
+// ThrottleDiagnostics structure reflects the filter_diagnostics object of a throttle
+// filter returned by MaxScale REST API <maxscale url>/v1/filters
+type ThrottleDiagnostics struct {
+	ThrottledSessions int `json:"throttled_sessions"`
+}
+
+// TeeDiagnostics structure reflects the filter_diagnostics object of a tee filter
+// returned by MaxScale REST API <maxscale url>/v1/filters
+type TeeDiagnostics struct {
+	BranchFailures int `json:"branch_failures"`
+}
+
+// NamedServerFilterDiagnostics structure reflects the filter_diagnostics object of a
+// namedserverfilter returned by MaxScale REST API <maxscale url>/v1/filters
+type NamedServerFilterDiagnostics struct {
+	TotalDiverted   int `json:"total_diverted"`
+	TotalUndiverted int `json:"total_undiverted"`
+}
+
+// Relationship reflects the objects related to a MaxScale object, e.g. the servers
+// of a service
+type Relationship struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// Duration is a duration in seconds. MaxScale reports durations either as numbers
+// or as strings with a unit suffix, e.g. "1.5s", "250ms" or "2min".
+type Duration float64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	seconds, err := decodeDuration(data, 1)
+	*d = Duration(seconds)
+	return err
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for durations in the config file
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	seconds, err := parseDuration(value)
+	*d = Duration(seconds)
+	return err
+}
+
+// MillisecondDuration is a duration in seconds that MaxScale reports in milliseconds
+// when no unit is given, e.g. monitor_interval.
+type MillisecondDuration float64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *MillisecondDuration) UnmarshalJSON(data []byte) error {
+	seconds, err := decodeDuration(data, 0.001)
+	*d = MillisecondDuration(seconds)
+	return err
+}
+
+// decodeDuration decodes a JSON duration into seconds. Values without unit are
+// multiplied by unit.
+func decodeDuration(data []byte, unit float64) (float64, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v * unit, nil
+	case string:
+		if number, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return number * unit, nil
+		}
+		return parseDuration(v)
+	}
+	return 0, fmt.Errorf("cannot decode %s as a duration", string(data))
+}
+
+var (
+	durationRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(h|min|m|s|ms)$`)
+	sizeRegexp     = regexp.MustCompile(`^([0-9]+)([kKmMgGtT])(i?)$`)
+
+	// millisecondParameters are the duration parameters reported as a number of milliseconds
+	// when no unit is given, like MillisecondDuration
+	millisecondParameters = []string{"monitor_interval", "disk_space_check_interval"}
+)
+
+// parameterValue converts a MaxScale parameter value into a number. Booleans become 0
+// or 1, durations seconds and sizes bytes. It reports false for other values.
+func parameterValue(parameter string, data json.RawMessage) (float64, bool) {
+	if contains(millisecondParameters, parameter) {
+		var duration MillisecondDuration
+		if err := json.Unmarshal(data, &duration); err != nil || string(data) == "null" {
+			return 0, false
+		}
+		return float64(duration), true
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return 0, false
+	}
+	return numericValue(value)
+}
+
+// numericValue converts a decoded JSON value into a number like parameterValue
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		return float64(boolToInt(v)), true
+	case string:
+		if number, err := strconv.ParseFloat(v, 64); err == nil {
+			return number, true
+		}
+		if durationRegexp.MatchString(v) {
+			if seconds, err := parseDuration(v); err == nil {
+				return seconds, true
+			}
+		}
+		if size := sizeRegexp.FindStringSubmatch(v); size != nil {
+			return parseSize(size[1], size[2], size[3] != ""), true
+		}
+	}
+	return 0, false
+}
+
+// selectJSON returns the value at a dot separated path, like attributes.statistics.connections,
+// in a decoded JSON value. Numeric path elements, also written as [0], index arrays.
+func selectJSON(value interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+	if path == "" {
+		return value, true
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// listenerSSL normalises the ssl parameter of a listener to "true" or "false"
+func listenerSSL(data json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "false"
+	}
+
+	switch strings.ToLower(jsonString(value)) {
+	case "true", "required", "enabled", "on":
+		return "true"
+	case "", "false", "disabled", "off":
+		return "false"
+	}
+	return jsonString(value)
+}
+
+// jsonString formats a decoded JSON value for a label
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// parseSize converts a MaxScale size into bytes. The suffixes K, M, G and T are
+// powers of 1000, or of 1024 when followed by i.
+func parseSize(number string, suffix string, binary bool) float64 {
+	size, _ := strconv.ParseFloat(number, 64)
+	base := 1000.0
+	if binary {
+		base = 1024
+	}
+	for _, unit := range "KMGT" {
+		size *= base
+		if strings.EqualFold(string(unit), suffix) {
+			break
+		}
+	}
+	return size
+}
+
+// parseDuration converts a MaxScale duration string into seconds. A value without
+// unit is taken as seconds.
+func parseDuration(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return seconds, nil
+	}
+	// Go doesn't know the "min" unit used by MaxScale
+	if strings.HasSuffix(value, "min") {
+		value = strings.TrimSuffix(value, "in")
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return duration.Seconds(), nil
+}
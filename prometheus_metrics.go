@@ -13,7 +13,11 @@
 
 package main
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 // Namespace for Prometheus
 const (
@@ -24,38 +28,122 @@ const (
 type Metric struct {
 	Desc      *prometheus.Desc
 	ValueType prometheus.ValueType
+
+	// Name and variable labels of the metric, prometheus.Desc does not expose them
+	name   string
+	labels []string
 }
 
 var (
 	serverLabelNames         = []string{"server", "address"}
 	serverUpLabelNames       = []string{"server", "address", "status"}
+	serverEventLabelNames    = []string{"server", "event"}
+	eventLabelNames          = []string{"event"}
 	serviceLabelNames        = []string{"name", "router"}
-	monitorLabelNames        = []string{"name", "cooperative_monitoring_locks"}
+	serviceStateLabelNames   = []string{"name", "router", "state"}
+	serviceServerLabelNames  = []string{"service", "server"}
+	serviceFilterLabelNames  = []string{"service", "filter", "position"}
+	monitorLabelNames        = []string{"name", "module"}
+	monitorStateLabelNames   = []string{"name", "module", "state"}
+	monitorLocksLabelNames   = []string{"name", "module", "cooperative_monitoring_locks"}
+	monitorServerLabelNames  = []string{"monitor", "server"}
+	monitorOnlyLabelNames    = []string{"monitor"}
+	monitorClusterLabelNames = []string{"monitor", "server", "cluster_uuid"}
+	monitorDomainLabelNames  = []string{"monitor", "server", "domain"}
+	monitorSlaveLabelNames   = []string{"monitor", "server", "connection", "master"}
+	moduleLabelNames         = []string{"module", "type", "version", "maturity", "api"}
+	qcLabelNames             = []string{"module"}
+	sessionLabelNames        = []string{"service"}
+	filterLabelNames         = []string{"filter"}
+	listenerLabelNames       = []string{"listener", "service"}
+	listenerStateLabelNames  = []string{"listener", "service", "state"}
+	listenerInfoLabelNames   = []string{"listener", "service", "address", "port", "protocol", "authenticator", "ssl"}
+	filterInfoLabelNames     = []string{"filter", "module", "services"}
+	sessionRuleLabelNames    = []string{"service", "rule"}
+	qcStatementLabelNames    = []string{"statement"}
+	routerLabelNames         = []string{"service"}
+	parameterLabelNames      = []string{"object_type", "object", "parameter"}
+	routerServerLabelNames   = []string{"service", "server"}
+	routerDomainLabelNames   = []string{"service", "domain"}
+	binlogRouterLabelNames   = []string{"service", "current_binlog", "master_state"}
 	maxscaleStatusLabelNames = []string{}
+	maxscaleInfoLabelNames   = []string{"version", "commit", "node_name"}
+	configSyncLabelNames     = []string{"checksum", "origin", "status"}
+	configSyncNodeLabelNames = []string{"node", "status"}
 	statusLabelNames         = []string{"id"}
+	statusStateLabelNames    = []string{"id", "state"}
 )
 
 type metrics map[string]Metric
 
+// newSessionMetrics creates the session metrics, the session counts are labelled
+// with the configured session attributes
+func newSessionMetrics(labels []string) metrics {
+	return metrics{
+		"sessions":                      newDesc("", "sessions", "Amount of sessions", labels, prometheus.GaugeValue),
+		"session_age":                   newDesc("session", "age_seconds", "Time since the sessions were connected", sessionLabelNames, prometheus.UntypedValue),
+		"session_idle":                  newDesc("session", "idle_seconds", "Time since the sessions were last active", sessionLabelNames, prometheus.UntypedValue),
+		"sessions_over_threshold":       newDesc("", "sessions_over_threshold", "Amount of sessions exceeding a threshold", sessionRuleLabelNames, prometheus.GaugeValue),
+		"sessions_oldest_offending_age": newDesc("", "sessions_oldest_offending_age_seconds", "Age of the oldest session exceeding a threshold", sessionLabelNames, prometheus.GaugeValue),
+	}
+}
+
 func newDesc(subsystem string, name string, help string, variableLabels []string, t prometheus.ValueType) Metric {
+	fqName := prometheus.BuildFQName(Namespace, subsystem, name)
 	return Metric{
-		Desc: prometheus.NewDesc(
-			prometheus.BuildFQName(Namespace, subsystem, name),
-			help, variableLabels, nil),
+		Desc:      prometheus.NewDesc(fqName, help, variableLabels, nil),
 		ValueType: t,
+		name:      fqName,
+		labels:    variableLabels,
+	}
+}
+
+// newServerMetrics creates the server metrics, the metrics of a server get the
+// configured extra labels after their own ones
+func newServerMetrics(extraLabels []string) metrics {
+	withExtraLabels := func(labels []string) []string {
+		return append(append([]string{}, labels...), extraLabels...)
+	}
+
+	return metrics{
+		"server_connections":          newDesc("server", "connections", "Amount of connections to the server", withExtraLabels(serverLabelNames), prometheus.GaugeValue),
+		"server_up":                   newDesc("server", "up", "Is the server up", withExtraLabels(serverUpLabelNames), prometheus.GaugeValue),
+		"server_last_event_timestamp": newDesc("server", "last_event_timestamp_seconds", "Unix time at which the monitor triggered the last event for the server", withExtraLabels(serverEventLabelNames), prometheus.GaugeValue),
+		"server_events_total":         newDesc("server", "events_total", "Number of monitor events seen by the exporter", eventLabelNames, prometheus.CounterValue),
 	}
 }
 
+// newCustomMetrics creates the metrics defined in the config file
+func newCustomMetrics(customMetrics []CustomMetric) metrics {
+	m := metrics{}
+	for _, metric := range customMetrics {
+		valueType := prometheus.GaugeValue
+		if metric.Type == "counter" {
+			valueType = prometheus.CounterValue
+		}
+		help := metric.Help
+		if help == "" {
+			help = fmt.Sprintf("Value of %s in %s", metric.Value, metric.Path)
+		}
+		m[metric.Name] = newDesc("", metric.Name, help, metric.labelNames(), valueType)
+	}
+	return m
+}
+
 // Exported MaxScale metrics for Prometheus
 var (
-	ServerMetrics = metrics{
-		"server_connections": newDesc("server", "connections", "Amount of connections to the server", serverLabelNames, prometheus.GaugeValue),
-		"server_up":          newDesc("server", "up", "Is the server up", serverUpLabelNames, prometheus.GaugeValue),
-	}
 	ServiceMetrics = metrics{
-		"service_current_sessions": newDesc("service", "current_sessions", "Amount of sessions currently active", serviceLabelNames, prometheus.GaugeValue),
-		"service_sessions_total":   newDesc("service", "total_sessions", "Total amount of sessions", serviceLabelNames, prometheus.CounterValue),
-		"service_max_connections":  newDesc("service", "max_connections", "Max connections allowed", serviceLabelNames, prometheus.GaugeValue),
+		"service_current_sessions":  newDesc("service", "current_sessions", "Amount of sessions currently active", serviceLabelNames, prometheus.GaugeValue),
+		"service_sessions_total":    newDesc("service", "total_sessions", "Total amount of sessions", serviceLabelNames, prometheus.CounterValue),
+		"service_max_connections":   newDesc("service", "max_connections", "Max connections allowed", serviceLabelNames, prometheus.GaugeValue),
+		"service_max_sessions":      newDesc("service", "max_sessions", "Maximum amount of concurrent sessions seen", serviceLabelNames, prometheus.GaugeValue),
+		"service_routed_packets":    newDesc("service", "routed_packets_total", "Total amount of routed packets", serviceLabelNames, prometheus.CounterValue),
+		"service_active_operations": newDesc("service", "active_operations", "Amount of operations currently in progress", serviceLabelNames, prometheus.GaugeValue),
+		"service_failed_auths":      newDesc("service", "failed_auths_total", "Total amount of failed authentications", serviceLabelNames, prometheus.CounterValue),
+		"service_state":             newDesc("service", "state", "Is the service in the given state", serviceStateLabelNames, prometheus.GaugeValue),
+		"service_server":            newDesc("service", "server", "Server used by the service", serviceServerLabelNames, prometheus.GaugeValue),
+		"service_filter":            newDesc("service", "filter", "Filter used by the service and its position in the filter chain", serviceFilterLabelNames, prometheus.GaugeValue),
+		"service_started":           newDesc("service", "started_timestamp_seconds", "Unix time at which the service was started", serviceLabelNames, prometheus.GaugeValue),
 	}
 
 	MaxscaleStatusMetrics = metrics{
@@ -64,6 +152,14 @@ var (
 		"status_writeq_high_water": newDesc("status", "writeq_high_water", "High water mark for network write buffer", maxscaleStatusLabelNames, prometheus.GaugeValue),
 		"status_writeq_low_water": newDesc("status", "writeq_low_water", "Low water mark for network write buffer", maxscaleStatusLabelNames, prometheus.GaugeValue),
 		"status_passive": newDesc("status", "passive", "Has passive mode", maxscaleStatusLabelNames, prometheus.GaugeValue),
+
+		"maxscale_info":           newDesc("maxscale", "info", "Version and node name of the MaxScale instance", maxscaleInfoLabelNames, prometheus.GaugeValue),
+		"maxscale_started_at":     newDesc("maxscale", "started_at_timestamp_seconds", "Unix time at which MaxScale was started", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"maxscale_activated_at":   newDesc("maxscale", "activated_at_timestamp_seconds", "Unix time at which MaxScale was last activated from passive mode", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"maxscale_restarts":       newDesc("maxscale", "restarts_total", "Amount of MaxScale restarts detected by the exporter", maxscaleStatusLabelNames, prometheus.CounterValue),
+		"config_sync_version":     newDesc("config_sync", "version", "Version of the synchronized configuration", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"config_sync_info":        newDesc("config_sync", "info", "Checksum, origin and status of the synchronized configuration", configSyncLabelNames, prometheus.GaugeValue),
+		"config_sync_node_status": newDesc("config_sync", "node_status", "Configuration synchronization status of a MaxScale node", configSyncNodeLabelNames, prometheus.GaugeValue),
 	}
 
 	StatusMetrics = metrics{
@@ -82,15 +178,138 @@ var (
 		"status_load_last_minute":                 newDesc("status", "load_last_minute", "The load during the last measured minute", statusLabelNames, prometheus.GaugeValue),
 		"status_load_last_hour":                   newDesc("status", "load_last_hour", "The load during the last measured hour", statusLabelNames, prometheus.GaugeValue),
 		"status_query_classifier_cache_size":      newDesc("status", "query_classifier_cache_size", "The query classifier cache size", statusLabelNames, prometheus.GaugeValue),
-		"status_query_classifier_cache_inserts":   newDesc("status", "query_classifier_cache_inserts", "The number of inserts into the query classifier cache", statusLabelNames, prometheus.GaugeValue),
-		"status_query_classifier_cache_hits":      newDesc("status", "query_classifier_cache_hits", "The number of hits in the query classifier cache", statusLabelNames, prometheus.GaugeValue),
-		"status_query_classifier_cache_misses":    newDesc("status", "query_classifier_cache_misses", "The number of misses in the query classifier cache", statusLabelNames, prometheus.GaugeValue),
-		"status_query_classifier_cache_evictions": newDesc("status", "query_classifier_cache_evictions", "The number of evictions in the query classifier cache", statusLabelNames, prometheus.GaugeValue),
+		"status_query_classifier_cache_inserts":   newDesc("status", "query_classifier_cache_inserts", "The number of inserts into the query classifier cache", statusLabelNames, prometheus.CounterValue),
+		"status_query_classifier_cache_hits":      newDesc("status", "query_classifier_cache_hits", "The number of hits in the query classifier cache", statusLabelNames, prometheus.CounterValue),
+		"status_query_classifier_cache_misses":    newDesc("status", "query_classifier_cache_misses", "The number of misses in the query classifier cache", statusLabelNames, prometheus.CounterValue),
+		"status_query_classifier_cache_evictions": newDesc("status", "query_classifier_cache_evictions", "The number of evictions in the query classifier cache", statusLabelNames, prometheus.CounterValue),
+		"status_sessions":                         newDesc("status", "sessions", "Amount of sessions handled by the thread", statusLabelNames, prometheus.GaugeValue),
+		"status_zombies":                          newDesc("status", "zombies", "Amount of zombie connections of the thread", statusLabelNames, prometheus.GaugeValue),
+		"status_listening":                        newDesc("status", "listening", "Is the thread listening for new connections", statusLabelNames, prometheus.GaugeValue),
+		"status_state":                            newDesc("status", "state", "Is the thread in the given state", statusStateLabelNames, prometheus.GaugeValue),
 	}
 
 	MonitorMetrics = metrics{
-		"monitor_primary":       newDesc("monitor", "primary", "Is a primary node", monitorLabelNames, prometheus.GaugeValue),
-		"monitor_auto_failover": newDesc("monitor", "auto_failover", "Is auto-failover enable", monitorLabelNames, prometheus.CounterValue),
-		"monitor_auto_rejoin":   newDesc("monitor", "auto_rejoin", "Is auto-rejoin enable", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_state":                             newDesc("monitor", "state", "Is the monitor in the given state", monitorStateLabelNames, prometheus.GaugeValue),
+		"monitor_ticks":                             newDesc("monitor", "ticks_total", "Amount of monitoring intervals completed", monitorLabelNames, prometheus.CounterValue),
+		"monitor_interval":                          newDesc("monitor", "interval_seconds", "Monitoring interval", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_server":                            newDesc("monitor", "server", "Server monitored by the monitor", monitorServerLabelNames, prometheus.GaugeValue),
+		"monitor_servers":                           newDesc("monitor", "servers", "Amount of monitored servers", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_primary":                           newDesc("monitor", "primary", "Does the monitor hold the lock majority and act as primary", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_locks_held":                        newDesc("monitor", "locks_held", "Amount of servers on which the monitor holds the cooperative monitoring lock", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_auto_failover":                     newDesc("monitor", "auto_failover", "Is auto-failover enabled", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_auto_rejoin":                       newDesc("monitor", "auto_rejoin", "Is auto-rejoin enabled", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_failcount":                         newDesc("monitor", "failcount", "Amount of failed monitoring intervals before a failover", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_switchover_timeout":                newDesc("monitor", "switchover_timeout_seconds", "Time limit for a switchover", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_failover_timeout":                  newDesc("monitor", "failover_timeout_seconds", "Time limit for a failover", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_enforce_read_only_slaves":          newDesc("monitor", "enforce_read_only_slaves", "Is read_only enforced on replicas", monitorLabelNames, prometheus.GaugeValue),
+		"monitor_cooperative_monitoring_locks_info": newDesc("monitor", "cooperative_monitoring_locks_info", "Cooperative monitoring locks mode of the monitor", monitorLocksLabelNames, prometheus.GaugeValue),
+
+		"mariadbmon_server_read_only":             newDesc("mariadbmon", "server_read_only", "Is read_only enabled on the server", monitorServerLabelNames, prometheus.GaugeValue),
+		"mariadbmon_server_gtid_current_sequence": newDesc("mariadbmon", "server_gtid_current_sequence", "Sequence number of gtid_current_pos per replication domain", monitorDomainLabelNames, prometheus.GaugeValue),
+		"mariadbmon_server_gtid_binlog_sequence":  newDesc("mariadbmon", "server_gtid_binlog_sequence", "Sequence number of gtid_binlog_pos per replication domain", monitorDomainLabelNames, prometheus.GaugeValue),
+		"mariadbmon_server_lock_held":             newDesc("mariadbmon", "server_lock_held", "Does the monitor hold the cooperative monitoring lock on the server", monitorServerLabelNames, prometheus.GaugeValue),
+		"mariadbmon_server_master_group":          newDesc("mariadbmon", "server_master_group", "Multi-master group of the server", monitorServerLabelNames, prometheus.GaugeValue),
+		"mariadbmon_slave_io_running":             newDesc("mariadbmon", "slave_io_running", "Is the replication IO thread running", monitorSlaveLabelNames, prometheus.GaugeValue),
+		"mariadbmon_slave_sql_running":            newDesc("mariadbmon", "slave_sql_running", "Is the replication SQL thread running", monitorSlaveLabelNames, prometheus.GaugeValue),
+		"mariadbmon_slave_seconds_behind_master":  newDesc("mariadbmon", "slave_seconds_behind_master", "Replication lag reported by the replica", monitorSlaveLabelNames, prometheus.GaugeValue),
+		"mariadbmon_slave_last_io_error":          newDesc("mariadbmon", "slave_last_io_error", "Has the replication IO thread reported an error", monitorSlaveLabelNames, prometheus.GaugeValue),
+		"mariadbmon_slave_last_sql_error":         newDesc("mariadbmon", "slave_last_sql_error", "Has the replication SQL thread reported an error", monitorSlaveLabelNames, prometheus.GaugeValue),
+
+		"galeramon_server_joined":                newDesc("galeramon", "server_joined", "Has the node joined the cluster", monitorServerLabelNames, prometheus.GaugeValue),
+		"galeramon_server_local_index":           newDesc("galeramon", "server_local_index", "Index of the node in the cluster (wsrep_local_index)", monitorServerLabelNames, prometheus.GaugeValue),
+		"galeramon_server_local_state":           newDesc("galeramon", "server_local_state", "State of the node (wsrep_local_state), 4 means synced", monitorServerLabelNames, prometheus.GaugeValue),
+		"galeramon_server_cluster_size":          newDesc("galeramon", "server_cluster_size", "Size of the cluster as seen by the node (wsrep_cluster_size)", monitorServerLabelNames, prometheus.GaugeValue),
+		"galeramon_server_cluster_info":          newDesc("galeramon", "server_cluster_info", "Cluster UUID seen by the node (wsrep_cluster_state_uuid)", monitorClusterLabelNames, prometheus.GaugeValue),
+		"galeramon_server_cluster_uuid_mismatch": newDesc("galeramon", "server_cluster_uuid_mismatch", "Does the cluster UUID of the node differ from the one of the majority of nodes", monitorServerLabelNames, prometheus.GaugeValue),
+		"galeramon_root_node_as_master":          newDesc("galeramon", "root_node_as_master", "Is the node with the lowest index selected as the master", monitorOnlyLabelNames, prometheus.GaugeValue),
+		"galeramon_use_priority":                 newDesc("galeramon", "use_priority", "Is the master selected by server priority", monitorOnlyLabelNames, prometheus.GaugeValue),
+		"galeramon_disable_master_failback":      newDesc("galeramon", "disable_master_failback", "Is master failback disabled", monitorOnlyLabelNames, prometheus.GaugeValue),
+		"galeramon_disable_master_role_setting":  newDesc("galeramon", "disable_master_role_setting", "Is assigning the master role disabled", monitorOnlyLabelNames, prometheus.GaugeValue),
+	}
+
+	RouterMetrics = metrics{
+		"rwsplit_queries":                     newDesc("rwsplit", "queries_total", "Total amount of routed queries", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_route_master":                newDesc("rwsplit", "route_master_total", "Amount of queries routed to the primary", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_route_slave":                 newDesc("rwsplit", "route_slave_total", "Amount of queries routed to replicas", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_route_all":                   newDesc("rwsplit", "route_all_total", "Amount of queries routed to all servers", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_rw_transactions":             newDesc("rwsplit", "rw_transactions_total", "Amount of read-write transactions", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_ro_transactions":             newDesc("rwsplit", "ro_transactions_total", "Amount of read-only transactions", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_replayed_transactions":       newDesc("rwsplit", "replayed_transactions_total", "Amount of replayed transactions", routerLabelNames, prometheus.CounterValue),
+		"rwsplit_server_queries":              newDesc("rwsplit", "server_queries_total", "Amount of queries routed to the server", routerServerLabelNames, prometheus.CounterValue),
+		"rwsplit_server_reads":                newDesc("rwsplit", "server_reads_total", "Amount of reads routed to the server", routerServerLabelNames, prometheus.CounterValue),
+		"rwsplit_server_writes":               newDesc("rwsplit", "server_writes_total", "Amount of writes routed to the server", routerServerLabelNames, prometheus.CounterValue),
+		"rwsplit_server_avg_sess_duration":    newDesc("rwsplit", "server_avg_session_duration_seconds", "Average duration of sessions using the server", routerServerLabelNames, prometheus.GaugeValue),
+		"rwsplit_server_avg_selects_per_sess": newDesc("rwsplit", "server_avg_selects_per_session", "Average amount of selects per session routed to the server", routerServerLabelNames, prometheus.GaugeValue),
+
+		"readconnroute_connections":         newDesc("readconnroute", "connections_total", "Total amount of connections", routerLabelNames, prometheus.CounterValue),
+		"readconnroute_current_connections": newDesc("readconnroute", "current_connections", "Amount of connections currently open", routerLabelNames, prometheus.GaugeValue),
+		"readconnroute_queries":             newDesc("readconnroute", "queries_total", "Total amount of routed queries", routerLabelNames, prometheus.CounterValue),
+
+		"schemarouter_queries":                     newDesc("schemarouter", "queries_total", "Total amount of routed queries", routerLabelNames, prometheus.CounterValue),
+		"schemarouter_sescmd_percentage":           newDesc("schemarouter", "sescmd_percentage", "Percentage of queries that were session commands", routerLabelNames, prometheus.GaugeValue),
+		"schemarouter_longest_sescmd_chain":        newDesc("schemarouter", "longest_sescmd_chain", "Longest chain of stored session commands", routerLabelNames, prometheus.GaugeValue),
+		"schemarouter_times_sescmd_limit_exceeded": newDesc("schemarouter", "sescmd_limit_exceeded_total", "Amount of times the session command history limit was exceeded", routerLabelNames, prometheus.CounterValue),
+		"schemarouter_longest_session":             newDesc("schemarouter", "longest_session_seconds", "Duration of the longest session", routerLabelNames, prometheus.GaugeValue),
+		"schemarouter_shortest_session":            newDesc("schemarouter", "shortest_session_seconds", "Duration of the shortest session", routerLabelNames, prometheus.GaugeValue),
+		"schemarouter_average_session":             newDesc("schemarouter", "average_session_seconds", "Average duration of sessions", routerLabelNames, prometheus.GaugeValue),
+		"schemarouter_shard_map_hits":              newDesc("schemarouter", "shard_map_hits_total", "Amount of shard map cache hits", routerLabelNames, prometheus.CounterValue),
+		"schemarouter_shard_map_misses":            newDesc("schemarouter", "shard_map_misses_total", "Amount of shard map cache misses", routerLabelNames, prometheus.CounterValue),
+
+		"binlogrouter_info":          newDesc("binlogrouter", "info", "Current binlog file and replication state of the binlog router", binlogRouterLabelNames, prometheus.GaugeValue),
+		"binlogrouter_gtid_sequence": newDesc("binlogrouter", "gtid_sequence", "Sequence number of the current GTID position per replication domain", routerDomainLabelNames, prometheus.GaugeValue),
+
+		"kafkacdc_events_sent": newDesc("kafkacdc", "events_sent_total", "Total amount of events sent to Kafka", routerLabelNames, prometheus.CounterValue),
+		"kafkacdc_errors":      newDesc("kafkacdc", "errors_total", "Total amount of errors while sending events to Kafka", routerLabelNames, prometheus.CounterValue),
+	}
+
+	ModuleMetrics = metrics{
+		"module_info": newDesc("module", "info", "Type, version and maturity of a loaded module", moduleLabelNames, prometheus.GaugeValue),
+	}
+
+	MemoryMetrics = metrics{
+		"memory_query_classifier":        newDesc("memory", "query_classifier_bytes", "Memory used by the query classifier cache", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"memory_zombies":                 newDesc("memory", "zombies_bytes", "Memory used by zombie connections", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"memory_sessions":                newDesc("memory", "sessions_bytes", "Memory used by sessions", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"memory_total":                   newDesc("memory", "total_bytes", "Total memory accounted by MaxScale", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"memory_thread_query_classifier": newDesc("memory", "thread_query_classifier_bytes", "Memory used by the query classifier cache of the thread", statusLabelNames, prometheus.GaugeValue),
+		"memory_thread_zombies":          newDesc("memory", "thread_zombies_bytes", "Memory used by zombie connections of the thread", statusLabelNames, prometheus.GaugeValue),
+		"memory_thread_sessions":         newDesc("memory", "thread_sessions_bytes", "Memory used by sessions of the thread", statusLabelNames, prometheus.GaugeValue),
+		"memory_thread_total":            newDesc("memory", "thread_total_bytes", "Total memory accounted by the thread", statusLabelNames, prometheus.GaugeValue),
+	}
+
+	QueryClassifierMetrics = metrics{
+		"query_classifier_info":           newDesc("query_classifier", "info", "Module used for classifying queries", qcLabelNames, prometheus.GaugeValue),
+		"query_classifier_cache_size":     newDesc("query_classifier", "cache_size_bytes", "Configured maximum size of the query classifier cache", maxscaleStatusLabelNames, prometheus.GaugeValue),
+		"query_classifier_statement_hits": newDesc("query_classifier", "statement_hits", "Cache hits of the cached statements with the most hits", qcStatementLabelNames, prometheus.GaugeValue),
+	}
+
+	FilterMetrics = metrics{
+		"filter_info": newDesc("filter", "info", "Module of the filter and the services using it", filterInfoLabelNames, prometheus.GaugeValue),
+
+		"cache_hits":          newDesc("cache", "hits_total", "Amount of cache hits", filterLabelNames, prometheus.CounterValue),
+		"cache_misses":        newDesc("cache", "misses_total", "Amount of cache misses", filterLabelNames, prometheus.CounterValue),
+		"cache_updates":       newDesc("cache", "updates_total", "Amount of cache updates", filterLabelNames, prometheus.CounterValue),
+		"cache_deletes":       newDesc("cache", "deletes_total", "Amount of cache deletes", filterLabelNames, prometheus.CounterValue),
+		"cache_evictions":     newDesc("cache", "evictions_total", "Amount of cache evictions", filterLabelNames, prometheus.CounterValue),
+		"cache_invalidations": newDesc("cache", "invalidations_total", "Amount of cache invalidations", filterLabelNames, prometheus.CounterValue),
+		"cache_size":          newDesc("cache", "size_bytes", "Memory used by the cache", filterLabelNames, prometheus.GaugeValue),
+		"cache_items":         newDesc("cache", "items", "Amount of items in the cache", filterLabelNames, prometheus.GaugeValue),
+
+		"throttle_throttled_sessions": newDesc("throttle", "throttled_sessions_total", "Amount of throttled sessions", filterLabelNames, prometheus.CounterValue),
+
+		"tee_branch_failures": newDesc("tee", "branch_failures_total", "Amount of failures of the branch service", filterLabelNames, prometheus.CounterValue),
+
+		"namedserverfilter_diverted":   newDesc("namedserverfilter", "diverted_total", "Amount of queries routed to the named servers", filterLabelNames, prometheus.CounterValue),
+		"namedserverfilter_undiverted": newDesc("namedserverfilter", "undiverted_total", "Amount of queries not matching any rule", filterLabelNames, prometheus.CounterValue),
+	}
+
+	ListenerMetrics = metrics{
+		"listener_state":   newDesc("listener", "state", "Is the listener in the given state", listenerStateLabelNames, prometheus.GaugeValue),
+		"listener_service": newDesc("listener", "service", "Service the listener belongs to", listenerLabelNames, prometheus.GaugeValue),
+		"listener_info":    newDesc("listener", "info", "Address, protocol and TLS settings of the listener", listenerInfoLabelNames, prometheus.GaugeValue),
+	}
+
+	ParameterMetrics = metrics{
+		"parameter": newDesc("", "parameter", "Value of a numeric, boolean, duration or size parameter of a MaxScale object, durations in seconds and sizes in bytes", parameterLabelNames, prometheus.GaugeValue),
 	}
 )
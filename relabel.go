@@ -0,0 +1,190 @@
+// Copyright 2019, Vitaly Bezgachev, vitaly.bezgachev [the_at_symbol] gmail.com, Kadir Tugan, kadir.tugan [the_at_symbol] gmail.com
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricNameLabel is the label holding the metric name during relabeling
+const metricNameLabel = "__name__"
+
+// relabelActions lists the supported relabeling actions
+var relabelActions = []string{"replace", "keep", "drop", "labeldrop"}
+
+// RelabelConfig is a rule of metric_relabel_configs applied by the exporter, with the
+// semantics of Prometheus
+type RelabelConfig struct {
+	// Labels whose values are joined with the separator and matched against the regex
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	// Label set to the expanded replacement by the replace action
+	TargetLabel string  `yaml:"target_label"`
+	Replacement *string `yaml:"replacement"`
+	// One of replace, keep, drop and labeldrop, default is replace
+	Action string `yaml:"action"`
+
+	regex *regexp.Regexp
+}
+
+// compile sets the defaults of the rule and compiles its regular expression
+func (r *RelabelConfig) compile() error {
+	if r.Action == "" {
+		r.Action = "replace"
+	}
+	if !contains(relabelActions, r.Action) {
+		return fmt.Errorf("unknown relabel action '%s', expected any of %v", r.Action, relabelActions)
+	}
+	if r.Separator == "" {
+		r.Separator = ";"
+	}
+	if r.Regex == "" {
+		r.Regex = "(.*)"
+	}
+	if r.Replacement == nil {
+		replacement := "$1"
+		r.Replacement = &replacement
+	}
+	if r.Action == "replace" && (r.TargetLabel == "" || r.TargetLabel == metricNameLabel) {
+		return fmt.Errorf("the replace action needs a target_label other than %s", metricNameLabel)
+	}
+
+	var err error
+	r.regex, err = regexp.Compile("^(?:" + r.Regex + ")$")
+	return err
+}
+
+// apply relabels the labels of a series in place and reports whether the series is kept
+func (r *RelabelConfig) apply(labels map[string]string) bool {
+	values := make([]string, len(r.SourceLabels))
+	for i, label := range r.SourceLabels {
+		values[i] = labels[label]
+	}
+	value := strings.Join(values, r.Separator)
+
+	switch r.Action {
+	case "keep":
+		return r.regex.MatchString(value)
+	case "drop":
+		return !r.regex.MatchString(value)
+	case "replace":
+		indexes := r.regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			return true
+		}
+		target := string(r.regex.ExpandString(nil, r.TargetLabel, value, indexes))
+		replacement := string(r.regex.ExpandString(nil, *r.Replacement, value, indexes))
+		if replacement == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = replacement
+		}
+	case "labeldrop":
+		for label := range labels {
+			if label != metricNameLabel && r.regex.MatchString(label) {
+				delete(labels, label)
+			}
+		}
+	}
+	return true
+}
+
+// relabelingGatherer adds the MaxScale node name to the exporter metrics, renames them to
+// the configured namespace and applies the relabeling rules to all gathered series
+type relabelingGatherer struct {
+	gatherer  prometheus.Gatherer
+	namespace string
+	configs   []RelabelConfig
+	// Label for the node name reported by the last scrape of MaxScale, not added while unknown
+	nodeNameLabel string
+	nodeName      func() string
+}
+
+// Gather implements prometheus.Gatherer
+func (g relabelingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+
+	nodeName := ""
+	if g.nodeNameLabel != "" && g.nodeName != nil {
+		nodeName = g.nodeName()
+	}
+
+	var relabeled []*dto.MetricFamily
+	for _, family := range families {
+		name := family.GetName()
+		exporterMetric := strings.HasPrefix(name, Namespace+"_")
+		if g.namespace != Namespace && exporterMetric {
+			name = g.namespace + strings.TrimPrefix(name, Namespace)
+			family.Name = &name
+		}
+
+		var metrics []*dto.Metric
+		for _, metric := range family.Metric {
+			if exporterMetric && nodeName != "" {
+				addLabel(metric, g.nodeNameLabel, nodeName)
+			}
+			if g.relabel(name, metric) {
+				metrics = append(metrics, metric)
+			}
+		}
+		if len(metrics) > 0 {
+			family.Metric = metrics
+			relabeled = append(relabeled, family)
+		}
+	}
+
+	return relabeled, err
+}
+
+// addLabel adds a label to a series, keeping the labels sorted by name
+func addLabel(metric *dto.Metric, label string, value string) {
+	metric.Label = append(metric.Label, &dto.LabelPair{Name: &label, Value: &value})
+	sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+}
+
+// relabel applies the relabeling rules to the labels of a series and reports whether
+// the series is kept
+func (g relabelingGatherer) relabel(name string, metric *dto.Metric) bool {
+	if len(g.configs) == 0 {
+		return true
+	}
+
+	labels := map[string]string{metricNameLabel: name}
+	for _, pair := range metric.Label {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	for i := range g.configs {
+		if !g.configs[i].apply(labels) {
+			return false
+		}
+	}
+
+	pairs := make([]*dto.LabelPair, 0, len(labels)-1)
+	for label, value := range labels {
+		if label != metricNameLabel {
+			label, value := label, value
+			pairs = append(pairs, &dto.LabelPair{Name: &label, Value: &value})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+	metric.Label = pairs
+	return true
+}